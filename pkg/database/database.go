@@ -9,8 +9,11 @@ import (
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/jmoiron/sqlx"
 	_ "github.com/lib/pq"
+	"github.com/marprin/postman-lib/pkg/metrics"
 	"github.com/opentracing/opentracing-go"
 	"github.com/opentracing/opentracing-go/ext"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
 )
 
 //Driver list
@@ -204,3 +207,11 @@ func (db *Database) NamedQueryContext(ctx context.Context, query string, args ..
 
 	return db.DB.NamedQueryContext(ctx, query, args)
 }
+
+// RegisterMetrics register the connection pool stats of the write and read database into the registerer
+func (s *Store) RegisterMetrics(reg prometheus.Registerer) error {
+	return metrics.Register(reg,
+		collectors.NewDBStatsCollector(s.Write.DB.DB, "write"),
+		collectors.NewDBStatsCollector(s.Read.DB.DB, "read"),
+	)
+}
@@ -0,0 +1,15 @@
+package errors
+
+import "github.com/marprin/postman-lib/shared/constants"
+
+// register the validation errors of the shared constants, so they reach the client as InvalidArgument
+// with the violated field instead of Unknown
+func init() {
+	Register(constants.ErrFromEmailIsRequired, InvalidArgument("FROM_EMAIL_IS_REQUIRED", "from_email", constants.ErrFromEmailIsRequired.Error()))
+	Register(constants.ErrFromEmailIsNotValid, InvalidArgument("FROM_EMAIL_IS_NOT_VALID", "from_email", constants.ErrFromEmailIsNotValid.Error()))
+	Register(constants.ErrFromAliasIsRequired, InvalidArgument("FROM_ALIAS_IS_REQUIRED", "from_name", constants.ErrFromAliasIsRequired.Error()))
+	Register(constants.ErrToEmailIsRequired, InvalidArgument("TO_EMAIL_IS_REQUIRED", "to_email", constants.ErrToEmailIsRequired.Error()))
+	Register(constants.ErrToEmailIsNotValid, InvalidArgument("TO_EMAIL_IS_NOT_VALID", "to_email", constants.ErrToEmailIsNotValid.Error()))
+	Register(constants.ErrSubjectIsRequired, InvalidArgument("SUBJECT_IS_REQUIRED", "subject", constants.ErrSubjectIsRequired.Error()))
+	Register(constants.ErrBodyIsRequired, InvalidArgument("BODY_IS_REQUIRED", "body", constants.ErrBodyIsRequired.Error()))
+}
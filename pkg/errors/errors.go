@@ -0,0 +1,213 @@
+package errors
+
+import (
+	stderrors "errors"
+	"sync"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type (
+	// FieldViolation describe the request field which failed the validation
+	FieldViolation struct {
+		Field       string
+		Description string
+	}
+
+	// Error is the domain error with the grpc code, it is sent as the google.rpc.ErrorInfo
+	// and google.rpc.BadRequest details of the grpc status
+	Error struct {
+		Code       codes.Code
+		Reason     string
+		Domain     string
+		Message    string
+		Metadata   map[string]string
+		Violations []FieldViolation
+	}
+
+	registration struct {
+		err error
+		def *Error
+	}
+)
+
+// DefaultDomain is the ErrorInfo domain of the postman errors
+const DefaultDomain = "postman"
+
+var (
+	registryMu sync.RWMutex
+	registry   []registration
+)
+
+// New create the error of the default domain
+func New(code codes.Code, reason, message string) *Error {
+	return &Error{Code: code, Reason: reason, Domain: DefaultDomain, Message: message}
+}
+
+// InvalidArgument create the InvalidArgument error with the violation of the field
+func InvalidArgument(reason, field, message string) *Error {
+	return New(codes.InvalidArgument, reason, message).WithViolation(field, message)
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// WithViolation return the copy of the error with the additional field violation
+func (e *Error) WithViolation(field, description string) *Error {
+	out := e.clone()
+	out.Violations = append(out.Violations, FieldViolation{Field: field, Description: description})
+	return out
+}
+
+// WithMetadata return the copy of the error with the additional ErrorInfo metadata
+func (e *Error) WithMetadata(key, value string) *Error {
+	out := e.clone()
+	out.Metadata = make(map[string]string, len(e.Metadata)+1)
+	for k, v := range e.Metadata {
+		out.Metadata[k] = v
+	}
+	out.Metadata[key] = value
+	return out
+}
+
+func (e *Error) clone() *Error {
+	out := *e
+	out.Violations = append([]FieldViolation(nil), e.Violations...)
+	return &out
+}
+
+// Is match the error with the same reason and domain, the registered domain error is matched
+// by its mapping, so errors.Is(err, constants.ErrToEmailIsNotValid) work on the client side
+func (e *Error) Is(target error) bool {
+	var other *Error
+	if !stderrors.As(target, &other) {
+		def, ok := lookup(target)
+		if !ok {
+			return false
+		}
+		other = def
+	}
+
+	return e.Reason != "" && e.Reason == other.Reason && e.Domain == other.Domain
+}
+
+// GRPCStatus implement the interface used by the status package, so the error can be returned from the handler
+func (e *Error) GRPCStatus() *status.Status {
+	st := status.New(e.Code, e.Message)
+
+	info := &errdetails.ErrorInfo{Reason: e.Reason, Domain: e.Domain, Metadata: e.Metadata}
+	if len(e.Violations) == 0 {
+		if detailed, err := st.WithDetails(info); err == nil {
+			return detailed
+		}
+		return st
+	}
+
+	badRequest := &errdetails.BadRequest{}
+	for _, v := range e.Violations {
+		badRequest.FieldViolations = append(badRequest.FieldViolations, &errdetails.BadRequest_FieldViolation{
+			Field:       v.Field,
+			Description: v.Description,
+		})
+	}
+
+	if detailed, err := st.WithDetails(info, badRequest); err == nil {
+		return detailed
+	}
+	return st
+}
+
+// Register map the domain error into the error, the registered error is converted by Convert
+func Register(domainErr error, def *Error) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	registry = append(registry, registration{err: domainErr, def: def})
+}
+
+func lookup(err error) (*Error, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	for _, r := range registry {
+		if stderrors.Is(err, r.err) {
+			return r.def, true
+		}
+	}
+
+	return nil, false
+}
+
+// Convert map the registered domain error into the error with the grpc status details,
+// the error which already carry the grpc status or is not registered is returned as it is
+func Convert(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var rich *Error
+	if stderrors.As(err, &rich) {
+		return rich
+	}
+
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+
+	def, ok := lookup(err)
+	if !ok {
+		return err
+	}
+
+	out := def.clone()
+	out.Message = err.Error()
+	return out
+}
+
+// FromError parse the grpc status error with the ErrorInfo and BadRequest details back into the error
+func FromError(err error) (*Error, bool) {
+	if err == nil {
+		return nil, false
+	}
+
+	var rich *Error
+	if stderrors.As(err, &rich) {
+		return rich, true
+	}
+
+	st, ok := status.FromError(err)
+	if !ok {
+		return nil, false
+	}
+
+	out := &Error{Code: st.Code(), Message: st.Message()}
+	found := false
+	for _, detail := range st.Details() {
+		switch d := detail.(type) {
+		case *errdetails.ErrorInfo:
+			out.Reason = d.Reason
+			out.Domain = d.Domain
+			out.Metadata = d.Metadata
+			found = true
+		case *errdetails.BadRequest:
+			for _, v := range d.FieldViolations {
+				out.Violations = append(out.Violations, FieldViolation{Field: v.Field, Description: v.Description})
+			}
+			found = true
+		}
+	}
+
+	return out, found
+}
+
+// FieldViolations return the field violations of the error, it is empty for the error without BadRequest details
+func FieldViolations(err error) []FieldViolation {
+	if rich, ok := FromError(err); ok {
+		return rich.Violations
+	}
+
+	return nil
+}
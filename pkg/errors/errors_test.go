@@ -0,0 +1,85 @@
+package errors
+
+import (
+	stderrors "errors"
+	"fmt"
+	"testing"
+
+	"github.com/marprin/postman-lib/proto/email"
+	"github.com/marprin/postman-lib/shared/constants"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// overTheWire simulate the status error received by the client
+func overTheWire(err error) error {
+	return status.ErrorProto(status.Convert(err).Proto())
+}
+
+func Test_Convert(t *testing.T) {
+	t.Run("should map the registered domain error", func(t *testing.T) {
+		err := Convert(fmt.Errorf("validate request: %w", constants.ErrToEmailIsNotValid))
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+		assert.Equal(t, "validate request: To email is not valid", status.Convert(err).Message())
+		assert.Equal(t, []FieldViolation{{Field: "to_email", Description: "To email is not valid"}}, FieldViolations(err))
+	})
+
+	t.Run("should keep the status and unknown error", func(t *testing.T) {
+		statusErr := status.Error(codes.NotFound, "not found")
+		assert.Equal(t, statusErr, Convert(statusErr))
+
+		plain := stderrors.New("plain")
+		assert.Equal(t, plain, Convert(plain))
+		assert.Nil(t, Convert(nil))
+	})
+}
+
+func Test_FromError(t *testing.T) {
+	t.Run("should parse the details on the client side", func(t *testing.T) {
+		err := overTheWire(Convert(constants.ErrToEmailIsNotValid))
+
+		rich, ok := FromError(err)
+		assert.True(t, ok)
+		assert.Equal(t, codes.InvalidArgument, rich.Code)
+		assert.Equal(t, "TO_EMAIL_IS_NOT_VALID", rich.Reason)
+		assert.Equal(t, DefaultDomain, rich.Domain)
+		assert.Equal(t, "to_email", rich.Violations[0].Field)
+		assert.True(t, stderrors.Is(rich, constants.ErrToEmailIsNotValid))
+		assert.False(t, stderrors.Is(rich, constants.ErrToEmailIsRequired))
+	})
+
+	t.Run("should keep the metadata and multiple violations", func(t *testing.T) {
+		custom := New(codes.FailedPrecondition, "QUOTA_EXCEEDED", "quota exceeded").
+			WithMetadata("limit", "100").
+			WithViolation("to_email", "too many recipients").
+			WithViolation("body", "too large")
+
+		rich, ok := FromError(overTheWire(custom))
+		assert.True(t, ok)
+		assert.Equal(t, map[string]string{"limit": "100"}, rich.Metadata)
+		assert.Len(t, rich.Violations, 2)
+		assert.True(t, stderrors.Is(rich, custom))
+	})
+
+	t.Run("should not parse the status without details", func(t *testing.T) {
+		_, ok := FromError(status.Error(codes.Internal, "internal"))
+		assert.False(t, ok)
+		assert.Nil(t, FieldViolations(stderrors.New("plain")))
+	})
+}
+
+func Test_ConstantsRegistration(t *testing.T) {
+	t.Run("should only report the fields of the email message", func(t *testing.T) {
+		fields := (&email.Email{}).ProtoReflect().Descriptor().Fields()
+
+		registryMu.RLock()
+		defer registryMu.RUnlock()
+		for _, r := range registry {
+			for _, v := range r.def.Violations {
+				assert.NotNil(t, fields.ByName(protoreflect.Name(v.Field)), v.Field)
+			}
+		}
+	})
+}
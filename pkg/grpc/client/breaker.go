@@ -0,0 +1,256 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type (
+	// BreakerState is the state of the circuit breaker, the value is exported by the state gauge
+	BreakerState int
+
+	// BreakerConfig is the config of the circuit breaker. The closed breaker open when the failure ratio of the
+	// calls in the window reach FailureRatio after at least MinRequests calls, the open breaker reject every call
+	// for CoolDown then become half-open, the half-open breaker let HalfOpenRequests calls through and close
+	// when all of them succeed or open again on the first failure
+	BreakerConfig struct {
+		FailureRatio     float64
+		MinRequests      int
+		Window           time.Duration
+		CoolDown         time.Duration
+		HalfOpenRequests int
+		// Codes is the codes counted as the failure, the other errors are counted as the success
+		Codes []codes.Code
+	}
+
+	// CircuitOpenError is returned without calling the server while the breaker of the target and method is open
+	CircuitOpenError struct {
+		Target string
+		Method string
+	}
+
+	// CircuitBreaker keep the breaker per target and method
+	CircuitBreaker struct {
+		cfg      BreakerConfig
+		now      func() time.Time
+		mu       sync.Mutex
+		breakers map[string]*breaker
+	}
+
+	breaker struct {
+		target   string
+		method   string
+		state    BreakerState
+		since    time.Time
+		requests int
+		failures int
+		inFlight int
+		// generation change on every state change, the result of the call allowed in another generation is ignored
+		generation uint64
+	}
+)
+
+const (
+	BreakerClosed BreakerState = iota
+	BreakerHalfOpen
+	BreakerOpen
+)
+
+var (
+	ErrCircuitBreakerIsOpen = "Circuit breaker is open"
+
+	// DefaultBreakerConfig open the breaker when half of at least 20 calls in 10 seconds fail
+	DefaultBreakerConfig = BreakerConfig{
+		FailureRatio:     0.5,
+		MinRequests:      20,
+		Window:           10 * time.Second,
+		CoolDown:         5 * time.Second,
+		HalfOpenRequests: 1,
+		Codes:            []codes.Code{codes.Unavailable, codes.DeadlineExceeded, codes.ResourceExhausted, codes.Internal},
+	}
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerHalfOpen:
+		return "half-open"
+	case BreakerOpen:
+		return "open"
+	}
+
+	return fmt.Sprintf("BreakerState(%d)", int(s))
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("%s: %s %s", ErrCircuitBreakerIsOpen, e.Target, e.Method)
+}
+
+// GRPCStatus return the Unavailable status so the caller handle it as any unavailable server
+func (e *CircuitOpenError) GRPCStatus() *status.Status {
+	return status.New(codes.Unavailable, e.Error())
+}
+
+// NewCircuitBreaker create the circuit breaker, the zero value of the config is replaced by DefaultBreakerConfig
+func NewCircuitBreaker(cfg BreakerConfig) *CircuitBreaker {
+	if cfg.FailureRatio <= 0 {
+		cfg.FailureRatio = DefaultBreakerConfig.FailureRatio
+	}
+	if cfg.MinRequests <= 0 {
+		cfg.MinRequests = DefaultBreakerConfig.MinRequests
+	}
+	if cfg.Window <= 0 {
+		cfg.Window = DefaultBreakerConfig.Window
+	}
+	if cfg.CoolDown <= 0 {
+		cfg.CoolDown = DefaultBreakerConfig.CoolDown
+	}
+	if cfg.HalfOpenRequests <= 0 {
+		cfg.HalfOpenRequests = DefaultBreakerConfig.HalfOpenRequests
+	}
+	if len(cfg.Codes) == 0 {
+		cfg.Codes = DefaultBreakerConfig.Codes
+	}
+
+	return &CircuitBreaker{
+		cfg:      cfg,
+		now:      time.Now,
+		breakers: map[string]*breaker{},
+	}
+}
+
+// State return the current state of the breaker of the target and method
+func (c *CircuitBreaker) State(target, method string) BreakerState {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.breakerFor(target, method).state
+}
+
+// UnaryClientInterceptor reject the call while the breaker of the target and method is open
+func (c *CircuitBreaker) UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		target := cc.Target()
+		generation, allowed := c.allow(target, method)
+		if !allowed {
+			return &CircuitOpenError{Target: target, Method: method}
+		}
+
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		c.record(target, method, generation, c.isFailure(err))
+		return err
+	}
+}
+
+func (c *CircuitBreaker) isFailure(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	code := status.Code(err)
+	for _, failure := range c.cfg.Codes {
+		if failure == code {
+			return true
+		}
+	}
+
+	return false
+}
+
+// breakerFor return the breaker of the target and method, the caller must hold the lock
+func (c *CircuitBreaker) breakerFor(target, method string) *breaker {
+	key := target + "|" + method
+	b, ok := c.breakers[key]
+	if !ok {
+		b = &breaker{target: target, method: method, since: c.now()}
+		c.breakers[key] = b
+		circuitBreakerState.WithLabelValues(target, method).Set(float64(BreakerClosed))
+	}
+
+	now := c.now()
+	switch {
+	case b.state == BreakerOpen && now.Sub(b.since) >= c.cfg.CoolDown:
+		c.setState(b, BreakerHalfOpen)
+	case b.state == BreakerClosed && now.Sub(b.since) >= c.cfg.Window:
+		// start the new counting window
+		b.since, b.requests, b.failures = now, 0, 0
+	}
+
+	return b
+}
+
+// setState move the breaker to the state and reset the counts, the caller must hold the lock
+func (c *CircuitBreaker) setState(b *breaker, state BreakerState) {
+	logrus.WithFields(logrus.Fields{
+		"Target": b.target,
+		"Method": b.method,
+		"From":   b.state.String(),
+		"To":     state.String(),
+	}).Warnln("Circuit breaker state is changed")
+
+	b.state = state
+	b.generation++
+	b.since = c.now()
+	b.requests, b.failures, b.inFlight = 0, 0, 0
+	circuitBreakerState.WithLabelValues(b.target, b.method).Set(float64(state))
+}
+
+// allow return true when the call can go through the breaker, with the generation of the breaker
+// which the result of the call is recorded to
+func (c *CircuitBreaker) allow(target, method string) (uint64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	b := c.breakerFor(target, method)
+	switch b.state {
+	case BreakerOpen:
+		return b.generation, false
+	case BreakerHalfOpen:
+		if b.inFlight >= c.cfg.HalfOpenRequests {
+			return b.generation, false
+		}
+		b.inFlight++
+	}
+
+	return b.generation, true
+}
+
+// record count the result of the call which went through the breaker, the call allowed before the last
+// state change is ignored so the slow call from before the outage is never counted as the half-open probe
+func (c *CircuitBreaker) record(target, method string, generation uint64, failed bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	b := c.breakerFor(target, method)
+	if b.generation != generation {
+		return
+	}
+
+	switch b.state {
+	case BreakerHalfOpen:
+		if failed {
+			c.setState(b, BreakerOpen)
+			return
+		}
+		b.requests++
+		if b.requests >= c.cfg.HalfOpenRequests {
+			c.setState(b, BreakerClosed)
+		}
+	case BreakerClosed:
+		b.requests++
+		if failed {
+			b.failures++
+		}
+		if b.requests >= c.cfg.MinRequests && float64(b.failures)/float64(b.requests) >= c.cfg.FailureRatio {
+			c.setState(b, BreakerOpen)
+		}
+	}
+}
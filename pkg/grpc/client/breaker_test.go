@@ -0,0 +1,132 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+const healthCheckMethod = "/grpc.health.v1.Health/Check"
+
+func Test_CircuitBreaker(t *testing.T) {
+	now := time.Now()
+	newBreaker := func() *CircuitBreaker {
+		cb := NewCircuitBreaker(BreakerConfig{
+			FailureRatio:     0.5,
+			MinRequests:      2,
+			Window:           time.Minute,
+			CoolDown:         10 * time.Second,
+			HalfOpenRequests: 1,
+		})
+		cb.now = func() time.Time { return now }
+		return cb
+	}
+	check := func(client grpc_health_v1.HealthClient) error {
+		_, err := client.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{})
+		return err
+	}
+	gauge := func() float64 {
+		return testutil.ToFloat64(circuitBreakerState.WithLabelValues("bufnet", healthCheckMethod))
+	}
+
+	t.Run("should open after the failure ratio and fail fast", func(t *testing.T) {
+		cb := newBreaker()
+		f := &failingServer{failures: 2, err: status.Error(codes.Unavailable, "unavailable")}
+		client := dialFailingServer(t, f, ClientConfig{CircuitBreaker: cb})
+
+		assert.Error(t, check(client))
+		assert.Equal(t, BreakerClosed, cb.State("bufnet", healthCheckMethod))
+		assert.Error(t, check(client))
+		assert.Equal(t, BreakerOpen, cb.State("bufnet", healthCheckMethod))
+		assert.Equal(t, float64(BreakerOpen), gauge())
+
+		err := check(client)
+		var openErr *CircuitOpenError
+		assert.True(t, errors.As(err, &openErr))
+		assert.Equal(t, healthCheckMethod, openErr.Method)
+		assert.Equal(t, codes.Unavailable, status.Code(err))
+		assert.Equal(t, 2, f.calls)
+	})
+
+	t.Run("should close after the half-open call succeed", func(t *testing.T) {
+		cb := newBreaker()
+		f := &failingServer{failures: 2, err: status.Error(codes.Unavailable, "unavailable")}
+		client := dialFailingServer(t, f, ClientConfig{CircuitBreaker: cb})
+
+		_, _ = check(client), check(client)
+		now = now.Add(10 * time.Second)
+		assert.Equal(t, BreakerHalfOpen, cb.State("bufnet", healthCheckMethod))
+		assert.Equal(t, float64(BreakerHalfOpen), gauge())
+
+		assert.NoError(t, check(client))
+		assert.Equal(t, BreakerClosed, cb.State("bufnet", healthCheckMethod))
+		assert.Equal(t, float64(BreakerClosed), gauge())
+	})
+
+	t.Run("should open again when the half-open call fail", func(t *testing.T) {
+		cb := newBreaker()
+		f := &failingServer{failures: 3, err: status.Error(codes.Unavailable, "unavailable")}
+		client := dialFailingServer(t, f, ClientConfig{CircuitBreaker: cb})
+
+		_, _ = check(client), check(client)
+		now = now.Add(10 * time.Second)
+		assert.Error(t, check(client))
+		assert.Equal(t, BreakerOpen, cb.State("bufnet", healthCheckMethod))
+		assert.Equal(t, 3, f.calls)
+	})
+
+	t.Run("should not count the error which is not a failure", func(t *testing.T) {
+		cb := newBreaker()
+		f := &failingServer{failures: 3, err: status.Error(codes.InvalidArgument, "invalid")}
+		client := dialFailingServer(t, f, ClientConfig{CircuitBreaker: cb})
+
+		for i := 0; i < 3; i++ {
+			assert.Equal(t, codes.InvalidArgument, status.Code(check(client)))
+		}
+		assert.Equal(t, BreakerClosed, cb.State("bufnet", healthCheckMethod))
+	})
+
+	t.Run("should start the new window after the window pass", func(t *testing.T) {
+		cb := newBreaker()
+		f := &failingServer{failures: 1, err: status.Error(codes.Unavailable, "unavailable")}
+		client := dialFailingServer(t, f, ClientConfig{CircuitBreaker: cb})
+
+		assert.Error(t, check(client))
+		now = now.Add(time.Minute)
+		assert.NoError(t, check(client))
+		assert.Equal(t, BreakerClosed, cb.State("bufnet", healthCheckMethod))
+	})
+
+	t.Run("should ignore the call allowed before the breaker open", func(t *testing.T) {
+		cb := newBreaker()
+		const target, method = "slow", healthCheckMethod
+
+		generation, allowed := cb.allow(target, method)
+		assert.True(t, allowed)
+
+		for i := 0; i < 2; i++ {
+			g, _ := cb.allow(target, method)
+			cb.record(target, method, g, true)
+		}
+		assert.Equal(t, BreakerOpen, cb.State(target, method))
+
+		now = now.Add(10 * time.Second)
+		assert.Equal(t, BreakerHalfOpen, cb.State(target, method))
+
+		// the slow success from before the outage is not the half-open probe
+		cb.record(target, method, generation, false)
+		assert.Equal(t, BreakerHalfOpen, cb.State(target, method))
+
+		probe, allowed := cb.allow(target, method)
+		assert.True(t, allowed)
+		cb.record(target, method, probe, false)
+		assert.Equal(t, BreakerClosed, cb.State(target, method))
+	})
+}
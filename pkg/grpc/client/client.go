@@ -4,33 +4,68 @@ import (
 	"context"
 	"time"
 
+	"github.com/marprin/postman-lib/pkg/redact"
+	"github.com/marprin/postman-lib/pkg/requestid"
+	"github.com/marprin/postman-lib/pkg/tracing"
 	"github.com/sirupsen/logrus"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/backoff"
+	"google.golang.org/grpc/metadata"
 )
 
-// UnaryInterceptor is used to log the request and response of a gRPC call
+// UnaryInterceptor is used to log the request and response of a gRPC call with the shared default redactor,
+// the request ID of the context is propagated to the server
 func UnaryInterceptor(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
-	start := time.Now()
-	err := invoker(ctx, method, req, reply, cc, opts...)
-	elapsed := time.Since(start)
-	if err != nil {
-		logrus.WithFields(logrus.Fields{
-			"Request": req,
-			"Error":   err,
-			"Elapsed": elapsed,
-		}).Errorln("Interceptor Log")
-	} else {
-		logrus.WithFields(logrus.Fields{
-			"Request":  req,
-			"Response": reply,
-			"Elapsed":  elapsed,
-		}).Infoln("Interceptor Log")
+	return NewUnaryInterceptor(nil)(ctx, method, req, reply, cc, invoker, opts...)
+}
+
+// NewUnaryInterceptor create the logging interceptor which redact the payload with the given redactor,
+// nil redactor use the shared default redactor
+func NewUnaryInterceptor(redactor *redact.Redactor) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		r := redactor
+		if r == nil {
+			r = redact.Default()
+		}
+
+		reqID, ok := requestid.FromContext(ctx)
+		if ok {
+			md, _ := metadata.FromOutgoingContext(ctx)
+			if len(md[requestid.MetadataKey]) == 0 {
+				ctx = metadata.AppendToOutgoingContext(ctx, requestid.MetadataKey, reqID)
+			}
+		}
+
+		start := time.Now()
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		elapsed := time.Since(start)
+
+		fields := logrus.Fields{
+			"RequestID": reqID,
+			"Method":    method,
+			"Elapsed":   elapsed,
+		}
+		sampled := r.Sampled(method)
+		if sampled {
+			fields["Request"] = r.Redact(req)
+		}
+
+		if err != nil {
+			fields["Error"] = err
+			logrus.WithFields(fields).Errorln("Interceptor Log")
+		} else {
+			if sampled {
+				fields["Response"] = r.Redact(reply)
+			}
+			logrus.WithFields(fields).Infoln("Interceptor Log")
+		}
+		return err
 	}
-	return err
 }
 
-// CreateDefaultgRPCConn is the default configuration for make the gRPC connection
+// CreateDefaultgRPCConn is the default configuration for make the gRPC connection, the timeout is in seconds
+//
+// Deprecated: use NewClientConn which return the dial error and take the explicit durations
 func CreateDefaultgRPCConn(endpoint string, timeout time.Duration) *grpc.ClientConn {
 	return CreategRPCConn(
 		endpoint,
@@ -39,11 +74,14 @@ func CreateDefaultgRPCConn(endpoint string, timeout time.Duration) *grpc.ClientC
 			Backoff:           backoff.DefaultConfig,
 			MinConnectTimeout: timeout * time.Second,
 		}),
-		grpc.WithUnaryInterceptor(UnaryInterceptor),
+		grpc.WithChainUnaryInterceptor(tracing.UnaryClientInterceptor(nil), UnaryInterceptor, ErrorInterceptor),
+		grpc.WithStreamInterceptor(tracing.StreamClientInterceptor(nil)),
 	)
 }
 
 // CreategRPCConn initialize gRPC connection with user can custom the params
+//
+// Deprecated: use Dial which return the dial error instead of the nil connection
 func CreategRPCConn(endpoint string, dialOptions ...grpc.DialOption) *grpc.ClientConn {
 	cc, err := grpc.Dial(endpoint, dialOptions...)
 	if err != nil {
@@ -0,0 +1,125 @@
+package client
+
+import (
+	"context"
+	"time"
+
+	"github.com/marprin/postman-lib/pkg/tracing"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/backoff"
+	"google.golang.org/grpc/credentials"
+)
+
+type (
+	// ClientConfig is the config of the grpc client connection, the durations are used as they are
+	ClientConfig struct {
+		Endpoint string
+		// MinConnectTimeout is the minimum time given to establish the connection, zero use the grpc default
+		MinConnectTimeout time.Duration
+		// Backoff is the reconnect backoff, zero value use backoff.DefaultConfig
+		Backoff backoff.Config
+		// Block wait until the connection is READY or the context is done
+		Block bool
+		// TransportCredentials secure the connection, nil dial without TLS
+		TransportCredentials credentials.TransportCredentials
+		// PerRPCCredentials authenticate every call, see NewClientSecretKeyCredentials and NewJWTCredentials
+		PerRPCCredentials credentials.PerRPCCredentials
+		// LoadBalancingPolicy select the balancer of the resolved addresses like RoundRobin, empty use PickFirst
+		LoadBalancingPolicy string
+		// Addresses resolve the endpoint to the static addresses, it is meant for the local and test setup
+		Addresses []string
+		// DNSRefreshInterval resolve the endpoint with DNS again on the interval, zero use the grpc DNS resolver,
+		// the authority of the dns://server/host endpoint is the DNS server to query
+		DNSRefreshInterval time.Duration
+		// CircuitBreaker reject the call while the breaker of the target and method is open
+		CircuitBreaker *CircuitBreaker
+		// RetryPolicy retry the failed unary call, nil does not retry unless the method has the policy in MethodRetryPolicies
+		RetryPolicy         *RetryPolicy
+		MethodRetryPolicies map[string]RetryPolicy
+		// DialOptions is appended after the default options
+		DialOptions []grpc.DialOption
+	}
+)
+
+// defaultMinConnectTimeout is the grpc default of the minimum connect timeout
+const defaultMinConnectTimeout = 20 * time.Second
+
+// dialOptions build the transport, connect params and the default interceptors from the config
+func (c ClientConfig) dialOptions() []grpc.DialOption {
+	minConnectTimeout := c.MinConnectTimeout
+	if minConnectTimeout <= 0 {
+		minConnectTimeout = defaultMinConnectTimeout
+	}
+
+	bo := c.Backoff
+	if bo == (backoff.Config{}) {
+		bo = backoff.DefaultConfig
+	}
+
+	unary := []grpc.UnaryClientInterceptor{tracing.UnaryClientInterceptor(nil)}
+	if c.CircuitBreaker != nil {
+		unary = append(unary, c.CircuitBreaker.UnaryClientInterceptor())
+	}
+	if c.RetryPolicy != nil || len(c.MethodRetryPolicies) > 0 {
+		policy := RetryPolicy{MaxAttempts: 1}
+		if c.RetryPolicy != nil {
+			policy = *c.RetryPolicy
+		}
+		unary = append(unary, NewRetryInterceptor(policy, c.MethodRetryPolicies))
+	}
+	unary = append(unary, UnaryInterceptor, ErrorInterceptor)
+
+	opts := []grpc.DialOption{
+		grpc.WithConnectParams(grpc.ConnectParams{
+			Backoff:           bo,
+			MinConnectTimeout: minConnectTimeout,
+		}),
+		grpc.WithChainUnaryInterceptor(unary...),
+		grpc.WithChainStreamInterceptor(tracing.StreamClientInterceptor(nil)),
+	}
+
+	if c.TransportCredentials != nil {
+		opts = append(opts, grpc.WithTransportCredentials(c.TransportCredentials))
+	} else {
+		opts = append(opts, grpc.WithInsecure())
+	}
+
+	if c.LoadBalancingPolicy != "" {
+		opts = append(opts, grpc.WithDefaultServiceConfig(loadBalancingServiceConfig(c.LoadBalancingPolicy)))
+	}
+
+	switch {
+	case len(c.Addresses) > 0:
+		opts = append(opts, grpc.WithResolvers(&staticResolverBuilder{addrs: c.Addresses}))
+	case c.DNSRefreshInterval > 0:
+		opts = append(opts, grpc.WithResolvers(&dnsResolverBuilder{interval: c.DNSRefreshInterval}))
+	}
+
+	if c.PerRPCCredentials != nil {
+		opts = append(opts, grpc.WithPerRPCCredentials(c.PerRPCCredentials))
+	}
+
+	if c.Block {
+		opts = append(opts, grpc.WithBlock())
+	}
+
+	return append(opts, c.DialOptions...)
+}
+
+// NewClientConn create the connection with the config, when Block is set it wait until
+// the connection is READY and return the error when the context is done before that
+func NewClientConn(ctx context.Context, cfg ClientConfig) (*grpc.ClientConn, error) {
+	return Dial(ctx, cfg.target(), cfg.dialOptions()...)
+}
+
+// Dial create the connection with the given options and return the dial error instead of logging it
+func Dial(ctx context.Context, endpoint string, dialOptions ...grpc.DialOption) (*grpc.ClientConn, error) {
+	cc, err := grpc.DialContext(ctx, endpoint, dialOptions...)
+	if err != nil {
+		return nil, err
+	}
+
+	logrus.Info("Successfully connect to gRPC Server: " + endpoint)
+	return cc, nil
+}
@@ -0,0 +1,89 @@
+package client
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// startBufServer start the grpc server with the health service on the in-memory listener
+func startBufServer(t *testing.T, opts ...grpc.ServerOption) *bufconn.Listener {
+	lis := bufconn.Listen(1024 * 1024)
+	s := grpc.NewServer(opts...)
+	grpc_health_v1.RegisterHealthServer(s, health.NewServer())
+	go func() { _ = s.Serve(lis) }()
+	t.Cleanup(s.Stop)
+
+	return lis
+}
+
+func bufDialer(lis *bufconn.Listener) grpc.DialOption {
+	return grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+		return lis.Dial()
+	})
+}
+
+func Test_NewClientConn(t *testing.T) {
+	t.Run("should block until the connection is ready", func(t *testing.T) {
+		lis := startBufServer(t)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		cc, err := NewClientConn(ctx, ClientConfig{
+			Endpoint:    "bufnet",
+			Block:       true,
+			DialOptions: []grpc.DialOption{bufDialer(lis)},
+		})
+		require.NoError(t, err)
+		defer cc.Close()
+
+		assert.Equal(t, connectivity.Ready, cc.GetState())
+		_, err = grpc_health_v1.NewHealthClient(cc).Check(ctx, &grpc_health_v1.HealthCheckRequest{})
+		assert.NoError(t, err)
+	})
+
+	t.Run("should return the error when the context expire before ready", func(t *testing.T) {
+		lis := bufconn.Listen(1024)
+		_ = lis.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		defer cancel()
+		cc, err := NewClientConn(ctx, ClientConfig{
+			Endpoint:          "bufnet",
+			Block:             true,
+			MinConnectTimeout: 100 * time.Millisecond,
+			DialOptions:       []grpc.DialOption{bufDialer(lis)},
+		})
+		assert.Nil(t, cc)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+
+	t.Run("should return the connection without waiting when not blocking", func(t *testing.T) {
+		lis := bufconn.Listen(1024)
+		_ = lis.Close()
+
+		cc, err := NewClientConn(context.Background(), ClientConfig{
+			Endpoint:    "bufnet",
+			DialOptions: []grpc.DialOption{bufDialer(lis)},
+		})
+		require.NoError(t, err)
+		assert.NoError(t, cc.Close())
+	})
+}
+
+func Test_Dial(t *testing.T) {
+	t.Run("should return the dial error instead of the nil connection", func(t *testing.T) {
+		cc, err := Dial(context.Background(), "bufnet")
+		assert.Nil(t, cc)
+		assert.Error(t, err)
+	})
+}
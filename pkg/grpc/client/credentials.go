@@ -0,0 +1,77 @@
+package client
+
+import (
+	"context"
+	"encoding/base64"
+
+	"google.golang.org/grpc/credentials"
+)
+
+type (
+	// TokenSource return the token of the call, it is called on every call so the rotated token is picked up
+	TokenSource func(ctx context.Context) (string, error)
+
+	clientSecretKeyCredentials struct {
+		token      string
+		requireTLS bool
+	}
+
+	jwtCredentials struct {
+		source     TokenSource
+		requireTLS bool
+	}
+)
+
+const (
+	// AuthorizationHeader is the metadata key of the client and secret key token, see server.AuthorizationHeader
+	AuthorizationHeader = "x-authorization"
+	// BearerAuthorizationHeader is the metadata key of the bearer token, see server.BearerAuthorizationHeader
+	BearerAuthorizationHeader = "authorization"
+)
+
+// EncodeClientSecretKey build the token of the client and secret key authentication, the same as server.EncodeClientSecretKey
+func EncodeClientSecretKey(clientKey, secretKey string) string {
+	return base64.StdEncoding.EncodeToString([]byte(clientKey + ":" + secretKey))
+}
+
+// NewClientSecretKeyCredentials create the per RPC credentials of the client and secret key authentication,
+// requireTLS refuse to send the secret on the connection without the transport security
+func NewClientSecretKeyCredentials(clientKey, secretKey string, requireTLS bool) credentials.PerRPCCredentials {
+	return &clientSecretKeyCredentials{
+		token:      EncodeClientSecretKey(clientKey, secretKey),
+		requireTLS: requireTLS,
+	}
+}
+
+func (c *clientSecretKeyCredentials) GetRequestMetadata(context.Context, ...string) (map[string]string, error) {
+	return map[string]string{AuthorizationHeader: c.token}, nil
+}
+
+func (c *clientSecretKeyCredentials) RequireTransportSecurity() bool {
+	return c.requireTLS
+}
+
+// NewJWTCredentials create the per RPC credentials which send the static token as the bearer token
+func NewJWTCredentials(token string, requireTLS bool) credentials.PerRPCCredentials {
+	return NewJWTSourceCredentials(func(context.Context) (string, error) {
+		return token, nil
+	}, requireTLS)
+}
+
+// NewJWTSourceCredentials create the per RPC credentials which send the token of the source as the bearer token
+func NewJWTSourceCredentials(source TokenSource, requireTLS bool) credentials.PerRPCCredentials {
+	return &jwtCredentials{source: source, requireTLS: requireTLS}
+}
+
+func (c *jwtCredentials) GetRequestMetadata(ctx context.Context, _ ...string) (map[string]string, error) {
+	token, err := c.source(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]string{BearerAuthorizationHeader: "Bearer " + token}, nil
+}
+
+func (c *jwtCredentials) RequireTransportSecurity() bool {
+	return c.requireTLS
+}
@@ -0,0 +1,106 @@
+package client
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/marprin/postman-lib/pkg/grpc/server"
+	"github.com/marprin/postman-lib/proto/email"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+)
+
+// dialMockServer run the mock server of pkg/grpc/server with the email service and dial it with the credentials
+func dialMockServer(t *testing.T, cfg *server.GRPCConfig, creds credentials.PerRPCCredentials) email.EmailServiceClient {
+	mock, err := server.NewGrpcServer(cfg, func(s *grpc.Server) {
+		email.RegisterEmailServiceServer(s, &email.UnimplementedEmailServiceServer{})
+	}).RunMock()
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = mock.Stop() })
+
+	cc, err := NewClientConn(context.Background(), ClientConfig{
+		Endpoint:          "bufnet",
+		PerRPCCredentials: creds,
+		DialOptions:       []grpc.DialOption{grpc.WithContextDialer(mock.Dialer())},
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = cc.Close() })
+
+	return email.NewEmailServiceClient(cc)
+}
+
+func signHS256(secret, claims string) string {
+	signed := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`)) + "." +
+		base64.RawURLEncoding.EncodeToString([]byte(claims))
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(signed))
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func Test_ClientSecretKeyCredentials(t *testing.T) {
+	cfg := &server.GRPCConfig{
+		AuthenticationType: server.AuthenticationTypeClientSecretKey,
+		Clients:            []server.ClientCredential{{ClientKey: "sms-service", SecretKey: "s3cr:et"}},
+	}
+
+	t.Run("should produce the same token as the server", func(t *testing.T) {
+		assert.Equal(t, server.EncodeClientSecretKey("sms-service", "s3cr:et"), EncodeClientSecretKey("sms-service", "s3cr:et"))
+	})
+
+	t.Run("should be authenticated by the server", func(t *testing.T) {
+		client := dialMockServer(t, cfg, NewClientSecretKeyCredentials("sms-service", "s3cr:et", false))
+		_, err := client.CreateEmail(context.Background(), &email.Email{})
+		assert.Equal(t, codes.Unimplemented, status.Code(err))
+	})
+
+	t.Run("should be rejected with the wrong secret", func(t *testing.T) {
+		client := dialMockServer(t, cfg, NewClientSecretKeyCredentials("sms-service", "wrong", false))
+		_, err := client.CreateEmail(context.Background(), &email.Email{})
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+
+	t.Run("should refuse the connection without TLS when TLS is required", func(t *testing.T) {
+		_, err := NewClientConn(context.Background(), ClientConfig{
+			Endpoint:          "bufnet",
+			PerRPCCredentials: NewClientSecretKeyCredentials("sms-service", "s3cr:et", true),
+		})
+		assert.Error(t, err)
+	})
+}
+
+func Test_JWTCredentials(t *testing.T) {
+	cfg := &server.GRPCConfig{AuthenticationType: server.AuthenticationTypeJWT, JWTSecret: "shared-secret"}
+
+	t.Run("should be authenticated by the server", func(t *testing.T) {
+		token := signHS256("shared-secret", fmt.Sprintf(`{"sub":"sms-service","exp":%d}`, time.Now().Add(time.Hour).Unix()))
+		client := dialMockServer(t, cfg, NewJWTCredentials(token, false))
+		_, err := client.CreateEmail(context.Background(), &email.Email{})
+		assert.Equal(t, codes.Unimplemented, status.Code(err))
+	})
+
+	t.Run("should be rejected with the token signed by another secret", func(t *testing.T) {
+		token := signHS256("other-secret", fmt.Sprintf(`{"sub":"sms-service","exp":%d}`, time.Now().Add(time.Hour).Unix()))
+		client := dialMockServer(t, cfg, NewJWTCredentials(token, false))
+		_, err := client.CreateEmail(context.Background(), &email.Email{})
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+
+	t.Run("should fail the call when the token source fail", func(t *testing.T) {
+		client := dialMockServer(t, cfg, NewJWTSourceCredentials(func(context.Context) (string, error) {
+			return "", errors.New("token is expired")
+		}, false))
+		_, err := client.CreateEmail(context.Background(), &email.Email{})
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+}
@@ -0,0 +1,19 @@
+package client
+
+import (
+	"context"
+
+	rpcerrors "github.com/marprin/postman-lib/pkg/errors"
+	"google.golang.org/grpc"
+)
+
+// ErrorInterceptor convert the status error with the ErrorInfo or BadRequest details into *errors.Error,
+// so the caller can match the domain error with errors.Is and read the field violations
+func ErrorInterceptor(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	err := invoker(ctx, method, req, reply, cc, opts...)
+	if rich, ok := rpcerrors.FromError(err); ok {
+		return rich
+	}
+
+	return err
+}
@@ -0,0 +1,36 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	rpcerrors "github.com/marprin/postman-lib/pkg/errors"
+	"github.com/marprin/postman-lib/shared/constants"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func Test_ErrorInterceptor(t *testing.T) {
+	invoke := func(err error) error {
+		return ErrorInterceptor(context.Background(), "/postman.email.EmailService/CreateEmail", nil, nil, nil,
+			func(context.Context, string, interface{}, interface{}, *grpc.ClientConn, ...grpc.CallOption) error {
+				return err
+			})
+	}
+
+	t.Run("should convert the status with details into the domain error", func(t *testing.T) {
+		wire := status.ErrorProto(status.Convert(rpcerrors.Convert(constants.ErrToEmailIsNotValid)).Proto())
+		err := invoke(wire)
+		assert.True(t, errors.Is(err, constants.ErrToEmailIsNotValid))
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+
+	t.Run("should keep the status without details", func(t *testing.T) {
+		wire := status.Error(codes.Unavailable, "unavailable")
+		assert.Equal(t, wire, invoke(wire))
+		assert.Nil(t, invoke(nil))
+	})
+}
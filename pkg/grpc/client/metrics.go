@@ -0,0 +1,16 @@
+package client
+
+import (
+	"github.com/marprin/postman-lib/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var circuitBreakerState = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "grpc_client_circuit_breaker_state",
+	Help: "State of the client circuit breaker per target and method, 0 is closed, 1 is half-open and 2 is open.",
+}, []string{"target", "grpc_method"})
+
+// RegisterMetrics register the circuit breaker gauge of the client package into the registerer
+func RegisterMetrics(reg prometheus.Registerer) error {
+	return metrics.Register(reg, circuitBreakerState)
+}
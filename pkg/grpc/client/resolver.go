@@ -0,0 +1,238 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/resolver"
+)
+
+type (
+	// staticResolverBuilder resolve every target to the fixed addresses
+	staticResolverBuilder struct {
+		addrs []string
+	}
+
+	staticResolver struct{}
+
+	// dnsResolverBuilder resolve the target with DNS on the interval, besides when grpc ask for it,
+	// the host is looked up with the DNS server of the target authority, or the system resolver without it
+	dnsResolverBuilder struct {
+		interval time.Duration
+		// minResolveNowInterval is the minimum interval between the lookup asked by grpc, zero use the default
+		minResolveNowInterval time.Duration
+		lookup                func(ctx context.Context, host string) ([]string, error)
+	}
+
+	dnsResolver struct {
+		host                  string
+		port                  string
+		cc                    resolver.ClientConn
+		lookup                func(ctx context.Context, host string) ([]string, error)
+		interval              time.Duration
+		minResolveNowInterval time.Duration
+		resolveNow            chan struct{}
+		cancel                context.CancelFunc
+		wg                    sync.WaitGroup
+	}
+)
+
+const (
+	// RoundRobin spread the calls across every ready address
+	RoundRobin = "round_robin"
+	// PickFirst send every call to the first ready address, it is the grpc default
+	PickFirst = "pick_first"
+
+	staticScheme = "static"
+	dnsScheme    = "dns"
+
+	defaultDNSPort       = "443"
+	defaultDNSServerPort = "53"
+
+	// defaultMinResolveNowInterval is the minimum interval between the lookup asked by grpc, the same as the grpc DNS resolver
+	defaultMinResolveNowInterval = 30 * time.Second
+)
+
+// loadBalancingServiceConfig return the service config which select the load balancing policy
+func loadBalancingServiceConfig(policy string) string {
+	return fmt.Sprintf(`{"loadBalancingConfig":[{%q:{}}]}`, policy)
+}
+
+func (b *staticResolverBuilder) Build(_ resolver.Target, cc resolver.ClientConn, _ resolver.BuildOptions) (resolver.Resolver, error) {
+	addrs := make([]resolver.Address, 0, len(b.addrs))
+	for _, addr := range b.addrs {
+		addrs = append(addrs, resolver.Address{Addr: addr})
+	}
+
+	return staticResolver{}, cc.UpdateState(resolver.State{Addresses: addrs})
+}
+
+func (b *staticResolverBuilder) Scheme() string {
+	return staticScheme
+}
+
+func (staticResolver) ResolveNow(resolver.ResolveNowOptions) {}
+
+func (staticResolver) Close() {}
+
+func (b *dnsResolverBuilder) Build(target resolver.Target, cc resolver.ClientConn, _ resolver.BuildOptions) (resolver.Resolver, error) {
+	host, port, err := net.SplitHostPort(target.Endpoint)
+	if err != nil {
+		host, port = target.Endpoint, defaultDNSPort
+	}
+
+	lookup := b.lookup
+	if lookup == nil {
+		res, err := authorityResolver(target.Authority)
+		if err != nil {
+			return nil, err
+		}
+		lookup = res.LookupHost
+	}
+
+	minResolveNowInterval := b.minResolveNowInterval
+	if minResolveNowInterval <= 0 {
+		minResolveNowInterval = defaultMinResolveNowInterval
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &dnsResolver{
+		host:                  host,
+		port:                  port,
+		cc:                    cc,
+		lookup:                lookup,
+		interval:              b.interval,
+		minResolveNowInterval: minResolveNowInterval,
+		resolveNow:            make(chan struct{}, 1),
+		cancel:                cancel,
+	}
+
+	r.wg.Add(1)
+	go r.watch(ctx)
+	return r, nil
+}
+
+func (b *dnsResolverBuilder) Scheme() string {
+	return dnsScheme
+}
+
+// authorityResolver return the resolver which query the DNS server of the authority,
+// the authority is host or host:port of the DNS server and the system resolver is used without it
+func authorityResolver(authority string) (*net.Resolver, error) {
+	if authority == "" {
+		return net.DefaultResolver, nil
+	}
+
+	host, port, err := net.SplitHostPort(authority)
+	if err != nil {
+		host, port = authority, defaultDNSServerPort
+		if strings.HasPrefix(host, "[") && strings.HasSuffix(host, "]") {
+			host = host[1 : len(host)-1]
+		}
+	}
+	if host == "" || port == "" || strings.ContainsAny(host, "[]") {
+		return nil, fmt.Errorf("invalid DNS server authority %q", authority)
+	}
+
+	addr := net.JoinHostPort(host, port)
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, network, addr)
+		},
+	}, nil
+}
+
+// watch resolve the host on every interval and every ResolveNow until the resolver is closed,
+// the ResolveNow is not resolved more often than the min resolve now interval
+func (r *dnsResolver) watch(ctx context.Context) {
+	defer r.wg.Done()
+
+	for {
+		// the resolution also satisfy the ResolveNow asked while waiting for it
+		select {
+		case <-r.resolveNow:
+		default:
+		}
+
+		r.resolve(ctx)
+		resolvedAt := time.Now()
+
+		if !r.wait(ctx, r.interval, r.resolveNow) {
+			return
+		}
+
+		// grpc ask for the resolution on every connection failure, wait the rest of the min interval
+		// unless the refresh interval come first
+		rest := r.minResolveNowInterval
+		if r.interval < rest {
+			rest = r.interval
+		}
+		if !r.wait(ctx, rest-time.Since(resolvedAt), nil) {
+			return
+		}
+	}
+}
+
+// wait block for the duration or until the ResolveNow, it return false when the resolver is closed
+func (r *dnsResolver) wait(ctx context.Context, d time.Duration, resolveNow <-chan struct{}) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+	case <-resolveNow:
+	}
+
+	return true
+}
+
+func (r *dnsResolver) resolve(ctx context.Context) {
+	hosts, err := r.lookup(ctx, r.host)
+	if err != nil {
+		r.cc.ReportError(err)
+		return
+	}
+
+	addrs := make([]resolver.Address, 0, len(hosts))
+	for _, host := range hosts {
+		addrs = append(addrs, resolver.Address{Addr: net.JoinHostPort(host, r.port)})
+	}
+
+	_ = r.cc.UpdateState(resolver.State{Addresses: addrs})
+}
+
+func (r *dnsResolver) ResolveNow(resolver.ResolveNowOptions) {
+	select {
+	case r.resolveNow <- struct{}{}:
+	default:
+	}
+}
+
+func (r *dnsResolver) Close() {
+	r.cancel()
+	r.wg.Wait()
+}
+
+// target return the dial target of the config, the static addresses take precedence over the DNS resolution
+func (c ClientConfig) target() string {
+	switch {
+	case len(c.Addresses) > 0:
+		return staticScheme + ":///" + c.Endpoint
+	case c.DNSRefreshInterval > 0 && !strings.Contains(c.Endpoint, "://"):
+		return dnsScheme + ":///" + c.Endpoint
+	}
+
+	return c.Endpoint
+}
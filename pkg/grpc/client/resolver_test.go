@@ -0,0 +1,242 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/resolver"
+	"google.golang.org/grpc/serviceconfig"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+type fakeResolverConn struct {
+	mu     sync.Mutex
+	states []resolver.State
+}
+
+func (f *fakeResolverConn) UpdateState(s resolver.State) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.states = append(f.states, s)
+	return nil
+}
+
+func (f *fakeResolverConn) updates() []resolver.State {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]resolver.State(nil), f.states...)
+}
+
+func (f *fakeResolverConn) ReportError(error)             {}
+func (f *fakeResolverConn) NewAddress([]resolver.Address) {}
+func (f *fakeResolverConn) NewServiceConfig(string)       {}
+func (f *fakeResolverConn) ParseServiceConfig(string) *serviceconfig.ParseResult {
+	return nil
+}
+
+func Test_RoundRobin(t *testing.T) {
+	var mu sync.Mutex
+	calls := map[string]int{}
+	listeners := map[string]*bufconn.Listener{}
+	addrs := []string{}
+	for i := 0; i < 3; i++ {
+		addr := fmt.Sprintf("email-%d", i)
+		addrs = append(addrs, addr)
+		listeners[addr] = startBufServer(t, grpc.UnaryInterceptor(
+			func(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+				mu.Lock()
+				calls[addr]++
+				mu.Unlock()
+				return handler(ctx, req)
+			}))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	cc, err := NewClientConn(ctx, ClientConfig{
+		Endpoint:            "email",
+		Addresses:           addrs,
+		LoadBalancingPolicy: RoundRobin,
+		Block:               true,
+		DialOptions: []grpc.DialOption{grpc.WithContextDialer(func(_ context.Context, addr string) (net.Conn, error) {
+			return listeners[addr].Dial()
+		})},
+	})
+	require.NoError(t, err)
+	defer cc.Close()
+	client := grpc_health_v1.NewHealthClient(cc)
+
+	// the balancer only pick the ready servers, wait until every server is picked
+	require.Eventually(t, func() bool {
+		if _, err := client.Check(ctx, &grpc_health_v1.HealthCheckRequest{}); err != nil {
+			return false
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		return len(calls) == len(addrs)
+	}, 3*time.Second, 10*time.Millisecond)
+
+	mu.Lock()
+	calls = map[string]int{}
+	mu.Unlock()
+
+	for i := 0; i < 30; i++ {
+		_, err := client.Check(ctx, &grpc_health_v1.HealthCheckRequest{})
+		require.NoError(t, err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	for _, addr := range addrs {
+		assert.Equal(t, 10, calls[addr], addr)
+	}
+}
+
+func Test_DNSResolver(t *testing.T) {
+	t.Run("should resolve the host again on the interval", func(t *testing.T) {
+		var mu sync.Mutex
+		lookups := 0
+		b := &dnsResolverBuilder{
+			interval: 10 * time.Millisecond,
+			lookup: func(_ context.Context, host string) ([]string, error) {
+				mu.Lock()
+				defer mu.Unlock()
+				lookups++
+				return []string{fmt.Sprintf("10.0.0.%d", lookups)}, nil
+			},
+		}
+
+		cc := &fakeResolverConn{}
+		r, err := b.Build(resolver.Target{Scheme: dnsScheme, Endpoint: "email.internal:50051"}, cc, resolver.BuildOptions{})
+		require.NoError(t, err)
+		assert.Eventually(t, func() bool { return len(cc.updates()) >= 3 }, time.Second, 5*time.Millisecond)
+		r.Close()
+
+		states := cc.updates()
+		assert.Equal(t, "10.0.0.1:50051", states[0].Addresses[0].Addr)
+		assert.Equal(t, "10.0.0.2:50051", states[1].Addresses[0].Addr)
+	})
+
+	t.Run("should use the default port without the port", func(t *testing.T) {
+		b := &dnsResolverBuilder{
+			interval: time.Hour,
+			lookup: func(context.Context, string) ([]string, error) {
+				return []string{"10.0.0.1"}, nil
+			},
+		}
+
+		cc := &fakeResolverConn{}
+		r, err := b.Build(resolver.Target{Scheme: dnsScheme, Endpoint: "email.internal"}, cc, resolver.BuildOptions{})
+		require.NoError(t, err)
+		assert.Eventually(t, func() bool { return len(cc.updates()) == 1 }, time.Second, 5*time.Millisecond)
+		r.Close()
+
+		assert.Equal(t, "10.0.0.1:443", cc.updates()[0].Addresses[0].Addr)
+	})
+}
+
+func Test_DNSResolverResolveNow(t *testing.T) {
+	t.Run("should rate limit the resolution asked by grpc", func(t *testing.T) {
+		var mu sync.Mutex
+		var lookups []time.Time
+		b := &dnsResolverBuilder{
+			interval:              time.Hour,
+			minResolveNowInterval: 100 * time.Millisecond,
+			lookup: func(context.Context, string) ([]string, error) {
+				mu.Lock()
+				defer mu.Unlock()
+				lookups = append(lookups, time.Now())
+				return []string{"10.0.0.1"}, nil
+			},
+		}
+		lookupTimes := func() []time.Time {
+			mu.Lock()
+			defer mu.Unlock()
+			return append([]time.Time(nil), lookups...)
+		}
+
+		cc := &fakeResolverConn{}
+		r, err := b.Build(resolver.Target{Scheme: dnsScheme, Endpoint: "email.internal:50051"}, cc, resolver.BuildOptions{})
+		require.NoError(t, err)
+		defer r.Close()
+		assert.Eventually(t, func() bool { return len(lookupTimes()) == 1 }, time.Second, 5*time.Millisecond)
+
+		for i := 0; i < 10; i++ {
+			r.ResolveNow(resolver.ResolveNowOptions{})
+			time.Sleep(5 * time.Millisecond)
+		}
+		assert.Len(t, lookupTimes(), 1)
+
+		assert.Eventually(t, func() bool { return len(lookupTimes()) == 2 }, time.Second, 5*time.Millisecond)
+		times := lookupTimes()
+		assert.True(t, times[1].Sub(times[0]) >= 100*time.Millisecond)
+
+		time.Sleep(150 * time.Millisecond)
+		assert.Len(t, lookupTimes(), 2)
+	})
+}
+
+func Test_authorityResolver(t *testing.T) {
+	t.Run("should use the system resolver without the authority", func(t *testing.T) {
+		res, err := authorityResolver("")
+		assert.Nil(t, err)
+		assert.Equal(t, net.DefaultResolver, res)
+	})
+
+	t.Run("should query the DNS server of the authority", func(t *testing.T) {
+		server, err := net.ListenPacket("udp", "127.0.0.1:0")
+		require.NoError(t, err)
+		defer server.Close()
+
+		res, err := authorityResolver(server.LocalAddr().String())
+		require.NoError(t, err)
+		assert.True(t, res.PreferGo)
+
+		conn, err := res.Dial(context.Background(), "udp", "8.8.8.8:53")
+		require.NoError(t, err)
+		defer conn.Close()
+		_, err = conn.Write([]byte("query"))
+		require.NoError(t, err)
+
+		buf := make([]byte, 16)
+		require.NoError(t, server.SetReadDeadline(time.Now().Add(time.Second)))
+		n, _, err := server.ReadFrom(buf)
+		assert.Nil(t, err)
+		assert.Equal(t, "query", string(buf[:n]))
+	})
+
+	t.Run("should use the default DNS port", func(t *testing.T) {
+		for _, authority := range []string{"8.8.8.8", "[::1]", "::1"} {
+			_, err := authorityResolver(authority)
+			assert.Nil(t, err, authority)
+		}
+	})
+
+	t.Run("should reject the invalid authority", func(t *testing.T) {
+		for _, authority := range []string{"8.8.8.8:", ":53", "[::1"} {
+			_, err := authorityResolver(authority)
+			assert.NotNil(t, err, authority)
+		}
+
+		b := &dnsResolverBuilder{interval: time.Hour}
+		_, err := b.Build(resolver.Target{Scheme: dnsScheme, Authority: ":53", Endpoint: "email:50051"}, &fakeResolverConn{}, resolver.BuildOptions{})
+		assert.NotNil(t, err)
+	})
+}
+
+func Test_ClientConfigTarget(t *testing.T) {
+	t.Run("should build the target of the resolver", func(t *testing.T) {
+		assert.Equal(t, "email:50051", ClientConfig{Endpoint: "email:50051"}.target())
+		assert.Equal(t, "static:///email", ClientConfig{Endpoint: "email", Addresses: []string{"a:1"}}.target())
+		assert.Equal(t, "dns:///email:50051", ClientConfig{Endpoint: "email:50051", DNSRefreshInterval: time.Minute}.target())
+		assert.Equal(t, "dns://8.8.8.8/email:50051", ClientConfig{Endpoint: "dns://8.8.8.8/email:50051", DNSRefreshInterval: time.Minute}.target())
+	})
+}
@@ -0,0 +1,147 @@
+package client
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"strconv"
+	"time"
+
+	"github.com/marprin/postman-lib/pkg/rpcmethod"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+type (
+	// RetryPolicy is the retry of the failed unary call, MaxAttempts include the first call so 1 disable the retry.
+	// The backoff before the n-th retry is InitialBackoff * Multiplier^(n-1) capped by MaxBackoff, then spread by Jitter
+	RetryPolicy struct {
+		MaxAttempts    int
+		InitialBackoff time.Duration
+		MaxBackoff     time.Duration
+		Multiplier     float64
+		// Jitter is the fraction of the backoff which is randomly added or removed, between 0 and 1
+		Jitter float64
+		Codes  []codes.Code
+	}
+)
+
+const (
+	// RetryAttemptMetadataKey is the outgoing metadata with the attempt number of the call, starting at 1
+	RetryAttemptMetadataKey = "x-retry-attempt"
+	// RetryAfterMetadataKey is the trailer with the seconds the server ask the client to wait, see server.RetryAfterMetadataKey
+	RetryAfterMetadataKey = "retry-after"
+)
+
+var (
+	// DefaultRetryPolicy retry the unavailable, exhausted and timed out call twice
+	DefaultRetryPolicy = RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: 100 * time.Millisecond,
+		MaxBackoff:     5 * time.Second,
+		Multiplier:     1.6,
+		Jitter:         0.2,
+		Codes:          []codes.Code{codes.Unavailable, codes.ResourceExhausted, codes.DeadlineExceeded},
+	}
+
+	retryJitter = rand.Float64
+)
+
+// retryable return true when the code of the error is in the retry codes of the policy
+func (p RetryPolicy) retryable(err error) bool {
+	code := status.Code(err)
+	for _, c := range p.Codes {
+		if c == code {
+			return true
+		}
+	}
+
+	return false
+}
+
+// backoff return the wait before the given retry, the first retry is 1
+func (p RetryPolicy) backoff(retry int) time.Duration {
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+
+	wait := float64(p.InitialBackoff) * math.Pow(multiplier, float64(retry-1))
+	if p.MaxBackoff > 0 && wait > float64(p.MaxBackoff) {
+		wait = float64(p.MaxBackoff)
+	}
+
+	if p.Jitter > 0 {
+		wait *= 1 + p.Jitter*(retryJitter()*2-1)
+	}
+
+	return time.Duration(wait)
+}
+
+// retryAfter return the wait asked by the server in the retry-after trailer, zero when it is not sent
+func retryAfter(trailer metadata.MD) time.Duration {
+	values := trailer.Get(RetryAfterMetadataKey)
+	if len(values) == 0 {
+		return 0
+	}
+
+	seconds, err := strconv.ParseInt(values[0], 10, 64)
+	if err != nil || seconds <= 0 {
+		return 0
+	}
+
+	return time.Duration(seconds) * time.Second
+}
+
+// NewRetryInterceptor create the interceptor which retry the failed unary call with the policy of the method,
+// the method without the policy in methodPolicies use the default policy. The retry stop when the wait
+// would pass the deadline of the call, and the wait is at least the retry-after sent by the server
+func NewRetryInterceptor(policy RetryPolicy, methodPolicies map[string]RetryPolicy) grpc.UnaryClientInterceptor {
+	patterns := make([]string, 0, len(methodPolicies))
+	for pattern := range methodPolicies {
+		patterns = append(patterns, pattern)
+	}
+
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		p := policy
+		if pattern, found := rpcmethod.LongestMatch(patterns, method); found {
+			p = methodPolicies[pattern]
+		}
+
+		var err error
+		for attempt := 1; ; attempt++ {
+			var trailer metadata.MD
+			attemptCtx := metadata.AppendToOutgoingContext(ctx, RetryAttemptMetadataKey, strconv.Itoa(attempt))
+			err = invoker(attemptCtx, method, req, reply, cc, append(opts, grpc.Trailer(&trailer))...)
+			if err == nil || attempt >= p.MaxAttempts || !p.retryable(err) || ctx.Err() != nil {
+				return err
+			}
+
+			wait := p.backoff(attempt)
+			if after := retryAfter(trailer); after > wait {
+				wait = after
+			}
+
+			if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+				return err
+			}
+
+			logrus.WithFields(logrus.Fields{
+				"Method":  method,
+				"Attempt": attempt,
+				"Wait":    wait,
+			}).WithError(err).Warnln("Retry the gRPC call")
+
+			timer := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return err
+			case <-timer.C:
+			}
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package client
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+type failingServer struct {
+	mu       sync.Mutex
+	failures int
+	err      error
+	trailer  metadata.MD
+	calls    int
+	attempts []string
+}
+
+// interceptor fail the first failures calls with the error then call the handler
+func (f *failingServer) interceptor(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	f.mu.Lock()
+	md, _ := metadata.FromIncomingContext(ctx)
+	f.attempts = append(f.attempts, md.Get(RetryAttemptMetadataKey)...)
+	f.calls++
+	fail := f.calls <= f.failures
+	f.mu.Unlock()
+
+	if fail {
+		if f.trailer != nil {
+			_ = grpc.SetTrailer(ctx, f.trailer)
+		}
+		return nil, f.err
+	}
+
+	return handler(ctx, req)
+}
+
+func dialFailingServer(t *testing.T, f *failingServer, cfg ClientConfig) grpc_health_v1.HealthClient {
+	lis := startBufServer(t, grpc.UnaryInterceptor(f.interceptor))
+
+	cfg.Endpoint = "bufnet"
+	cfg.DialOptions = []grpc.DialOption{bufDialer(lis)}
+	cc, err := NewClientConn(context.Background(), cfg)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = cc.Close() })
+
+	return grpc_health_v1.NewHealthClient(cc)
+}
+
+func Test_RetryInterceptor(t *testing.T) {
+	policy := RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     10 * time.Millisecond,
+		Multiplier:     2,
+		Codes:          []codes.Code{codes.Unavailable, codes.ResourceExhausted},
+	}
+
+	t.Run("should retry the retryable code and send the attempt number", func(t *testing.T) {
+		f := &failingServer{failures: 2, err: status.Error(codes.Unavailable, "unavailable")}
+		client := dialFailingServer(t, f, ClientConfig{RetryPolicy: &policy})
+
+		_, err := client.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"1", "2", "3"}, f.attempts)
+	})
+
+	t.Run("should return the last error when the attempts are used up", func(t *testing.T) {
+		f := &failingServer{failures: 5, err: status.Error(codes.Unavailable, "unavailable")}
+		client := dialFailingServer(t, f, ClientConfig{RetryPolicy: &policy})
+
+		_, err := client.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{})
+		assert.Equal(t, codes.Unavailable, status.Code(err))
+		assert.Len(t, f.attempts, 3)
+	})
+
+	t.Run("should not retry the code which is not retryable", func(t *testing.T) {
+		f := &failingServer{failures: 1, err: status.Error(codes.InvalidArgument, "invalid")}
+		client := dialFailingServer(t, f, ClientConfig{RetryPolicy: &policy})
+
+		_, err := client.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+		assert.Len(t, f.attempts, 1)
+	})
+
+	t.Run("should use the policy of the method", func(t *testing.T) {
+		f := &failingServer{failures: 1, err: status.Error(codes.Unavailable, "unavailable")}
+		client := dialFailingServer(t, f, ClientConfig{
+			RetryPolicy:         &policy,
+			MethodRetryPolicies: map[string]RetryPolicy{"/grpc.health.v1.Health/*": {MaxAttempts: 1}},
+		})
+
+		_, err := client.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{})
+		assert.Equal(t, codes.Unavailable, status.Code(err))
+		assert.Len(t, f.attempts, 1)
+	})
+
+	t.Run("should wait the retry-after sent by the server", func(t *testing.T) {
+		f := &failingServer{
+			failures: 1,
+			err:      status.Error(codes.ResourceExhausted, "limited"),
+			trailer:  metadata.Pairs(RetryAfterMetadataKey, "1"),
+		}
+		client := dialFailingServer(t, f, ClientConfig{RetryPolicy: &policy})
+
+		start := time.Now()
+		_, err := client.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{})
+		assert.NoError(t, err)
+		assert.GreaterOrEqual(t, int64(time.Since(start)), int64(time.Second))
+	})
+
+	t.Run("should not wait past the deadline of the call", func(t *testing.T) {
+		f := &failingServer{
+			failures: 1,
+			err:      status.Error(codes.ResourceExhausted, "limited"),
+			trailer:  metadata.Pairs(RetryAfterMetadataKey, "5"),
+		}
+		client := dialFailingServer(t, f, ClientConfig{RetryPolicy: &policy})
+
+		ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+		defer cancel()
+		start := time.Now()
+		_, err := client.Check(ctx, &grpc_health_v1.HealthCheckRequest{})
+		assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+		assert.Less(t, int64(time.Since(start)), int64(500*time.Millisecond))
+		assert.Len(t, f.attempts, 1)
+	})
+}
+
+func Test_RetryPolicyBackoff(t *testing.T) {
+	t.Run("should grow the backoff up to the max", func(t *testing.T) {
+		p := RetryPolicy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: 300 * time.Millisecond, Multiplier: 2}
+		assert.Equal(t, 100*time.Millisecond, p.backoff(1))
+		assert.Equal(t, 200*time.Millisecond, p.backoff(2))
+		assert.Equal(t, 300*time.Millisecond, p.backoff(3))
+	})
+
+	t.Run("should spread the backoff with the jitter", func(t *testing.T) {
+		defer func(f func() float64) { retryJitter = f }(retryJitter)
+		p := RetryPolicy{InitialBackoff: 100 * time.Millisecond, Multiplier: 2, Jitter: 0.5}
+
+		retryJitter = func() float64 { return 0 }
+		assert.Equal(t, 50*time.Millisecond, p.backoff(1))
+		retryJitter = func() float64 { return 1 }
+		assert.Equal(t, 150*time.Millisecond, p.backoff(1))
+	})
+}
@@ -0,0 +1,123 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/base64"
+	"strings"
+
+	"github.com/marprin/postman-lib/pkg/rpcmethod"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+type (
+	// ClientCredential is the client and secret key pair allowed to call the grpc server,
+	// empty AllowedMethods means the client can call every method
+	ClientCredential struct {
+		ClientKey      string
+		SecretKey      string
+		AllowedMethods []string
+		Roles          []string
+		Scopes         []string
+	}
+
+	contextKey string
+)
+
+const clientIDContextKey contextKey = "client_id"
+
+// ClientIDFromContext return the authenticated client key of the current request
+func ClientIDFromContext(ctx context.Context) (string, bool) {
+	clientID, ok := ctx.Value(clientIDContextKey).(string)
+	return clientID, ok
+}
+
+// EncodeClientSecretKey build the authorization token for the client and secret key authentication
+func EncodeClientSecretKey(clientKey, secretKey string) string {
+	return base64.StdEncoding.EncodeToString([]byte(clientKey + ":" + secretKey))
+}
+
+// decodeClientSecretKey parse the base64 encoded "clientKey:secretKey" token
+func decodeClientSecretKey(token string) (string, string, bool) {
+	decToken, err := base64.StdEncoding.DecodeString(token)
+	if err != nil {
+		return "", "", false
+	}
+
+	parts := strings.SplitN(string(decToken), ":", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", false
+	}
+
+	return parts[0], parts[1], true
+}
+
+// clientCredentials return every registered client, including the default ClientKey and SecretKey pair
+func (g *grpcServer) clientCredentials() []ClientCredential {
+	clients := make([]ClientCredential, 0, len(g.cfg.Clients)+1)
+	if g.cfg.ClientKey != "" && g.cfg.SecretKey != "" {
+		clients = append(clients, ClientCredential{ClientKey: g.cfg.ClientKey, SecretKey: g.cfg.SecretKey})
+	}
+
+	return append(clients, g.cfg.Clients...)
+}
+
+// isMethodAllowed return true when the method is in the allowed list of the client
+func (c *ClientCredential) isMethodAllowed(method string) bool {
+	if len(c.AllowedMethods) == 0 {
+		return true
+	}
+
+	for _, pattern := range c.AllowedMethods {
+		if rpcmethod.Match(pattern, method) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// authenticateClientSecretKey validate the "clientKey:secretKey" token from the incoming metadata,
+// every registered client is compared in constant time so the response time does not leak which key matched
+func (g *grpcServer) authenticateClientSecretKey(ctx context.Context, method string) (context.Context, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ctx, status.Error(codes.Internal, ErrFailedExtractMetadata)
+	}
+
+	if len(md[AuthorizationHeader]) == 0 {
+		return ctx, status.Error(codes.Unauthenticated, ErrAuthorizationTokenIsNotPresent)
+	}
+
+	clientKey, secretKey, ok := decodeClientSecretKey(md[AuthorizationHeader][0])
+	if !ok {
+		return ctx, status.Error(codes.Unauthenticated, ErrAuthorizationTokenIsNotValid)
+	}
+
+	var matched *ClientCredential
+	clients := g.clientCredentials()
+	for i := range clients {
+		keyMatch := subtle.ConstantTimeCompare([]byte(clients[i].ClientKey), []byte(clientKey))
+		secretMatch := subtle.ConstantTimeCompare([]byte(clients[i].SecretKey), []byte(secretKey))
+		if keyMatch&secretMatch == 1 && matched == nil {
+			matched = &clients[i]
+		}
+	}
+
+	if matched == nil {
+		return ctx, status.Error(codes.Unauthenticated, ErrAuthorizationTokenIsNotValid)
+	}
+
+	if !matched.isMethodAllowed(method) {
+		return ctx, status.Error(codes.PermissionDenied, ErrMethodIsNotAllowed)
+	}
+
+	ctx = context.WithValue(ctx, claimsContextKey, &Claims{
+		Subject: matched.ClientKey,
+		Roles:   matched.Roles,
+		Scopes:  matched.Scopes,
+	})
+	return context.WithValue(ctx, clientIDContextKey, matched.ClientKey), nil
+}
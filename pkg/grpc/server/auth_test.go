@@ -0,0 +1,73 @@
+package server
+
+import (
+	"context"
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func Test_ClientSecretKeyAuthentication(t *testing.T) {
+	cfg := &GRPCConfig{
+		AuthenticationType: AuthenticationTypeClientSecretKey,
+		ClientKey:          "default-client",
+		SecretKey:          "default-secret",
+		Clients: []ClientCredential{
+			{ClientKey: "email-client", SecretKey: "email-secret", AllowedMethods: []string{"/postman.test.TestService/*"}},
+			{ClientKey: "sms-client", SecretKey: "sms-secret", AllowedMethods: []string{"/postman.sms.SMSService/SendSMS"}},
+		},
+	}
+
+	var clientID string
+	conn := dialTestServer(t, cfg, WithUnaryInterceptors(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		clientID, _ = ClientIDFromContext(ctx)
+		return handler(ctx, req)
+	}))
+
+	invoke := func(token string) error {
+		ctx := metadata.AppendToOutgoingContext(context.Background(), AuthorizationHeader, token)
+		return conn.Invoke(ctx, testUnaryMethod, wrapperspb.String("hello"), &wrapperspb.StringValue{})
+	}
+
+	t.Run("should reject malformed token", func(t *testing.T) {
+		for _, token := range []string{
+			"",
+			"not-base64!",
+			base64.StdEncoding.EncodeToString([]byte("default-client")),
+			base64.StdEncoding.EncodeToString([]byte(":default-secret")),
+			base64.StdEncoding.EncodeToString([]byte("default-client:")),
+		} {
+			err := invoke(token)
+			assert.Equal(t, codes.Unauthenticated, status.Code(err), token)
+			assert.Equal(t, ErrAuthorizationTokenIsNotValid, status.Convert(err).Message(), token)
+		}
+	})
+
+	t.Run("should reject wrong secret key", func(t *testing.T) {
+		err := invoke(EncodeClientSecretKey("default-client", "email-secret"))
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+
+	t.Run("should accept default client and attach client id", func(t *testing.T) {
+		err := invoke(EncodeClientSecretKey("default-client", "default-secret"))
+		assert.Nil(t, err)
+		assert.Equal(t, "default-client", clientID)
+	})
+
+	t.Run("should accept client with allowed method", func(t *testing.T) {
+		err := invoke(EncodeClientSecretKey("email-client", "email-secret"))
+		assert.Nil(t, err)
+		assert.Equal(t, "email-client", clientID)
+	})
+
+	t.Run("should deny client without allowed method", func(t *testing.T) {
+		err := invoke(EncodeClientSecretKey("sms-client", "sms-secret"))
+		assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	})
+}
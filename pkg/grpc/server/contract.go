@@ -1,9 +1,20 @@
 package server
 
-import "google.golang.org/grpc/test/bufconn"
+import (
+	"context"
+	"net"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
 
 // GrpcServer contract
 type GrpcServer interface {
 	Run() error
-	RunMock() (*bufconn.Listener, error)
+	RunContext(ctx context.Context) error
+	RunMock() (*MockServer, error)
+	MetricsRegistry() *prometheus.Registry
+	Ready() <-chan struct{}
+	StartErr() error
+	Addr() net.Addr
+	MetricAddr() net.Addr
 }
@@ -0,0 +1,82 @@
+package server
+
+import (
+	"context"
+	"time"
+
+	"github.com/marprin/postman-lib/pkg/rpcmethod"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type (
+	// MethodTimeout is the timeout of the method when the client does not send the deadline,
+	// the method can be an exact full method or a wildcard pattern, the timeout is in seconds
+	MethodTimeout struct {
+		Method  string
+		Timeout uint
+	}
+)
+
+const (
+	deadlineReasonExpired = "expired"
+	deadlineReasonTimeout = "timeout"
+)
+
+var ErrDeadlineIsExpired = "Request deadline is already expired"
+
+// initMethodTimeouts index the method timeouts of the config by the method pattern
+func (g *grpcServer) initMethodTimeouts() {
+	g.methodTimeouts = make(map[string]time.Duration, len(g.cfg.MethodTimeouts))
+	for _, mt := range g.cfg.MethodTimeouts {
+		if _, ok := g.methodTimeouts[mt.Method]; !ok {
+			g.timeoutPatterns = append(g.timeoutPatterns, mt.Method)
+		}
+		g.methodTimeouts[mt.Method] = seconds(mt.Timeout)
+	}
+}
+
+// methodTimeout return the timeout of the exact method, otherwise the timeout of the longest matching pattern
+// and the default timeout when no pattern match
+func (g *grpcServer) methodTimeout(method string) time.Duration {
+	if pattern, found := rpcmethod.LongestMatch(g.timeoutPatterns, method); found {
+		return g.methodTimeouts[pattern]
+	}
+
+	return seconds(g.cfg.DefaultTimeout)
+}
+
+// withDeadline reject the request which arrive already expired, apply the method timeout when the client
+// does not send the deadline and cap the deadline to the max timeout
+func (g *grpcServer) withDeadline(ctx context.Context, method string) (context.Context, context.CancelFunc, error) {
+	deadline, hasDeadline := ctx.Deadline()
+	if hasDeadline && !time.Now().Before(deadline) {
+		g.deadlineExceeded.WithLabelValues(method, deadlineReasonExpired).Inc()
+		return ctx, func() {}, status.Error(codes.DeadlineExceeded, ErrDeadlineIsExpired)
+	}
+
+	timeout := time.Duration(0)
+	if !hasDeadline {
+		timeout = g.methodTimeout(method)
+	}
+
+	if maxTimeout := seconds(g.cfg.MaxTimeout); maxTimeout > 0 {
+		if (!hasDeadline && (timeout <= 0 || timeout > maxTimeout)) || (hasDeadline && time.Until(deadline) > maxTimeout) {
+			timeout = maxTimeout
+		}
+	}
+
+	if timeout <= 0 {
+		return ctx, func() {}, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	return ctx, cancel, nil
+}
+
+// countDeadlineExceeded count the request which run out of the deadline while it is handled
+func (g *grpcServer) countDeadlineExceeded(ctx context.Context, method string) {
+	if ctx.Err() == context.DeadlineExceeded {
+		g.deadlineExceeded.WithLabelValues(method, deadlineReasonTimeout).Inc()
+	}
+}
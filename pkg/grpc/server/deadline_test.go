@@ -0,0 +1,101 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func Test_methodTimeout(t *testing.T) {
+	inst := NewGrpcServer(&GRPCConfig{
+		DefaultTimeout: 5,
+		MethodTimeouts: []MethodTimeout{
+			{Method: "/postman.email.EmailService/*", Timeout: 30},
+			{Method: "/postman.email.EmailService/GetStatus", Timeout: 1},
+		},
+	}, nil).(*grpcServer)
+
+	assert.Equal(t, 30*time.Second, inst.methodTimeout("/postman.email.EmailService/CreateEmail"))
+	assert.Equal(t, time.Second, inst.methodTimeout("/postman.email.EmailService/GetStatus"))
+	assert.Equal(t, 5*time.Second, inst.methodTimeout("/postman.sms.SMSService/SendSMS"))
+}
+
+func Test_Deadline(t *testing.T) {
+	var handlerDeadline time.Time
+	var hasDeadline bool
+	slow := false
+	newServer := func(cfg *GRPCConfig) (*grpcServer, *grpc.ClientConn) {
+		inst := NewGrpcServer(cfg, func(s *grpc.Server) {
+			s.RegisterService(&testServiceDesc, struct{}{})
+		}, WithUnaryInterceptors(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			handlerDeadline, hasDeadline = ctx.Deadline()
+			if slow {
+				<-ctx.Done()
+				return nil, status.FromContextError(ctx.Err()).Err()
+			}
+			return handler(ctx, req)
+		}))
+		mock, err := inst.RunMock()
+		assert.Nil(t, err)
+		t.Cleanup(func() { mock.Stop() })
+
+		conn, err := mock.Dial(context.Background())
+		assert.Nil(t, err)
+		t.Cleanup(func() { conn.Close() })
+
+		return inst.(*grpcServer), conn
+	}
+
+	t.Run("should apply the default timeout without client deadline", func(t *testing.T) {
+		_, conn := newServer(&GRPCConfig{DefaultTimeout: 10})
+		err := conn.Invoke(context.Background(), testUnaryMethod, wrapperspb.String("hello"), &wrapperspb.StringValue{})
+		assert.Nil(t, err)
+		assert.True(t, hasDeadline)
+		assert.WithinDuration(t, time.Now().Add(10*time.Second), handlerDeadline, time.Second)
+	})
+
+	t.Run("should cap the client deadline to the max timeout", func(t *testing.T) {
+		_, conn := newServer(&GRPCConfig{MaxTimeout: 2})
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+
+		err := conn.Invoke(ctx, testUnaryMethod, wrapperspb.String("hello"), &wrapperspb.StringValue{})
+		assert.Nil(t, err)
+		assert.WithinDuration(t, time.Now().Add(2*time.Second), handlerDeadline, time.Second)
+	})
+
+	t.Run("should keep the request without deadline when nothing is configured", func(t *testing.T) {
+		_, conn := newServer(&GRPCConfig{})
+		err := conn.Invoke(context.Background(), testUnaryMethod, wrapperspb.String("hello"), &wrapperspb.StringValue{})
+		assert.Nil(t, err)
+		assert.False(t, hasDeadline)
+	})
+
+	t.Run("should count the request which exceed the deadline", func(t *testing.T) {
+		slow = true
+		defer func() { slow = false }()
+
+		inst, conn := newServer(&GRPCConfig{MaxTimeout: 1})
+		err := conn.Invoke(context.Background(), testUnaryMethod, wrapperspb.String("hello"), &wrapperspb.StringValue{})
+		assert.Equal(t, codes.DeadlineExceeded, status.Code(err))
+		assert.Equal(t, float64(1), testutil.ToFloat64(inst.deadlineExceeded.WithLabelValues(testUnaryMethod, deadlineReasonTimeout)))
+	})
+}
+
+func Test_withDeadline(t *testing.T) {
+	inst := NewGrpcServer(&GRPCConfig{}, nil).(*grpcServer)
+
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+
+	_, _, err := inst.withDeadline(ctx, testUnaryMethod)
+	assert.Equal(t, codes.DeadlineExceeded, status.Code(err))
+	assert.Equal(t, float64(1), testutil.ToFloat64(inst.deadlineExceeded.WithLabelValues(testUnaryMethod, deadlineReasonExpired)))
+}
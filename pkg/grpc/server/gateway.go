@@ -0,0 +1,346 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/marprin/postman-lib/pkg/requestid"
+	"github.com/marprin/postman-lib/pkg/response"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+type (
+	// gateway transcode the HTTP/JSON request into the unary grpc call through the in-process connection
+	gateway struct {
+		conn        *grpc.ClientConn
+		methods     map[string]protoreflect.MethodDescriptor
+		maxBodySize int64
+	}
+
+	// gatewayServer is the HTTP listener of the gateway with the in-process connection to the grpc server behind it
+	gatewayServer struct {
+		httpServer *http.Server
+		conn       *grpc.ClientConn
+		timeout    uint
+	}
+
+	// inProcessListener mark every accepted connection as the in-process connection
+	inProcessListener struct {
+		*bufconn.Listener
+	}
+
+	// inProcessConn is the connection accepted by the inProcessListener, it never leave the process
+	inProcessConn struct {
+		net.Conn
+	}
+
+	// inProcessCredentials skip the TLS handshake of the in-process connection and delegate the others,
+	// the gateway request is already verified by the TLS of the HTTP listener
+	inProcessCredentials struct {
+		credentials.TransportCredentials
+	}
+
+	inProcessAuthInfo struct {
+		credentials.CommonAuthInfo
+	}
+)
+
+const (
+	// GatewayMetadataPrefix is the prefix of the HTTP header which is forwarded as the grpc metadata without the prefix
+	GatewayMetadataPrefix = "Grpc-Metadata-"
+
+	ErrGatewayMethodIsNotFound      = "Method is not found"
+	ErrGatewayMethodIsNotAllowed    = "Only POST method is allowed"
+	ErrGatewayRequestBodyIsInvalid  = "Request body is not valid"
+	ErrGatewayRequestBodyIsTooLarge = "Request body is too large"
+)
+
+var (
+	gatewayMarshalOptions   = protojson.MarshalOptions{UseProtoNames: true, EmitUnpopulated: true}
+	gatewayUnmarshalOptions = protojson.UnmarshalOptions{}
+
+	// gatewayForwardedHeaders is the HTTP header which is forwarded as the grpc metadata as it is
+	gatewayForwardedHeaders = []string{AuthorizationHeader, BearerAuthorizationHeader, requestid.MetadataKey}
+)
+
+// newGateway expose every unary method registered in the grpc server whose descriptor is in the proto registry,
+// the grpc internal services like health and reflection are not exposed. The request body larger than
+// maxBodySize is rejected
+func newGateway(s *grpc.Server, conn *grpc.ClientConn, maxBodySize int) *gateway {
+	gw := &gateway{conn: conn, methods: map[string]protoreflect.MethodDescriptor{}, maxBodySize: int64(maxBodySize)}
+
+	for name := range s.GetServiceInfo() {
+		if strings.HasPrefix(name, "grpc.") {
+			continue
+		}
+
+		desc, err := protoregistry.GlobalFiles.FindDescriptorByName(protoreflect.FullName(name))
+		if err != nil {
+			logrus.Warnf("Service %s is not exposed on the HTTP gateway, the descriptor is not found", name)
+			continue
+		}
+
+		svc, ok := desc.(protoreflect.ServiceDescriptor)
+		if !ok {
+			continue
+		}
+
+		methods := svc.Methods()
+		for i := 0; i < methods.Len(); i++ {
+			md := methods.Get(i)
+			if md.IsStreamingClient() || md.IsStreamingServer() {
+				continue
+			}
+			gw.methods[fmt.Sprintf("/%s/%s", name, md.Name())] = md
+		}
+	}
+
+	return gw
+}
+
+// newMessage create the generated message of the descriptor, the dynamic message is used when it is not registered
+func newMessage(md protoreflect.MessageDescriptor) proto.Message {
+	if mt, err := protoregistry.GlobalTypes.FindMessageByName(md.FullName()); err == nil {
+		return mt.New().Interface()
+	}
+
+	return dynamicpb.NewMessage(md)
+}
+
+// outgoingMetadata forward the authorization, request id and the Grpc-Metadata- prefixed headers
+func outgoingMetadata(r *http.Request) metadata.MD {
+	md := metadata.MD{}
+	for _, key := range gatewayForwardedHeaders {
+		if values := r.Header.Values(key); len(values) > 0 {
+			md.Append(key, values...)
+		}
+	}
+
+	for key, values := range r.Header {
+		if strings.HasPrefix(key, GatewayMetadataPrefix) {
+			md.Append(strings.TrimPrefix(key, GatewayMetadataPrefix), values...)
+		}
+	}
+
+	return md
+}
+
+// httpStatusFromCode map the grpc status code into the HTTP status code
+func httpStatusFromCode(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return http.StatusOK
+	case codes.Canceled:
+		return 499
+	case codes.InvalidArgument, codes.FailedPrecondition, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	}
+
+	return http.StatusInternalServerError
+}
+
+func writeGatewayError(w http.ResponseWriter, r *http.Request, httpStatus int, message string) {
+	response.Response(w, r, httpStatus, nil, []response.ErrorPayload{{Message: message}}, nil, httpStatus)
+}
+
+func (gw *gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	md, ok := gw.methods[r.URL.Path]
+	if !ok {
+		writeGatewayError(w, r, http.StatusNotFound, ErrGatewayMethodIsNotFound)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeGatewayError(w, r, http.StatusMethodNotAllowed, ErrGatewayMethodIsNotAllowed)
+		return
+	}
+
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, gw.maxBodySize))
+	if err != nil {
+		// the max bytes reader fail after reading the limit when the body is larger
+		if int64(len(body)) >= gw.maxBodySize {
+			writeGatewayError(w, r, http.StatusRequestEntityTooLarge, ErrGatewayRequestBodyIsTooLarge)
+			return
+		}
+		writeGatewayError(w, r, http.StatusBadRequest, ErrGatewayRequestBodyIsInvalid)
+		return
+	}
+
+	req := newMessage(md.Input())
+	if len(body) > 0 {
+		if err := gatewayUnmarshalOptions.Unmarshal(body, req); err != nil {
+			writeGatewayError(w, r, http.StatusBadRequest, ErrGatewayRequestBodyIsInvalid)
+			return
+		}
+	}
+
+	var header metadata.MD
+	resp := newMessage(md.Output())
+	ctx := metadata.NewOutgoingContext(r.Context(), outgoingMetadata(r))
+	err = gw.conn.Invoke(ctx, r.URL.Path, req, resp, grpc.Header(&header))
+
+	for key, values := range header {
+		for _, v := range values {
+			w.Header().Add(key, v)
+		}
+	}
+
+	if err != nil {
+		st := status.Convert(err)
+		writeGatewayError(w, r, httpStatusFromCode(st.Code()), st.Message())
+		return
+	}
+
+	out, err := gatewayMarshalOptions.Marshal(resp)
+	if err != nil {
+		writeGatewayError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(out)
+}
+
+func (l inProcessListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+
+	return inProcessConn{conn}, nil
+}
+
+func (c inProcessCredentials) ServerHandshake(conn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	if _, ok := conn.(inProcessConn); ok {
+		return conn, inProcessAuthInfo{credentials.CommonAuthInfo{SecurityLevel: credentials.NoSecurity}}, nil
+	}
+
+	return c.TransportCredentials.ServerHandshake(conn)
+}
+
+func (c inProcessCredentials) Clone() credentials.TransportCredentials {
+	return inProcessCredentials{c.TransportCredentials.Clone()}
+}
+
+func (inProcessAuthInfo) AuthType() string {
+	return "in-process"
+}
+
+// newInProcessConn serve the grpc server on the in-process listener and dial it, the connection does not
+// use TLS since it never leave the process, the TLS server skip the handshake through inProcessCredentials
+func newInProcessConn(s *grpc.Server) (*grpc.ClientConn, error) {
+	lis := inProcessListener{bufconn.Listen(mockBufferSize)}
+	go func() {
+		if err := s.Serve(lis); err != nil && err != grpc.ErrServerStopped {
+			logrus.Errorf("Failed to serve the in-process GRPC server, error: %s", err)
+		}
+	}()
+
+	return grpc.DialContext(context.Background(), "bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return lis.Dial() }),
+		grpc.WithInsecure(),
+	)
+}
+
+// startGateway serve the HTTP/JSON gateway on the gateway address through the in-process connection to the grpc
+// server, so the request run through the same interceptors as the grpc request, except the peer certificate which
+// is not available. The gateway is served with the same TLS and client certificate verification as the grpc server
+func (g *grpcServer) startGateway(s *grpc.Server) (*gatewayServer, error) {
+	var tlsConfig *tls.Config
+	if g.cfg.UseTLS {
+		var err error
+		if tlsConfig, err = newHTTPTLSConfig(g.cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	conn, err := newInProcessConn(s)
+	if err != nil {
+		return nil, err
+	}
+
+	transport := *g.cfg
+	transport.applyTransportDefaults()
+
+	gatewayAddr := fmt.Sprintf("%s:%d", g.cfg.GatewayHost, g.cfg.GatewayPort)
+	gw := &gatewayServer{
+		httpServer: &http.Server{
+			Handler:   newGateway(s, conn, transport.MaxRecvMsgSize),
+			Addr:      gatewayAddr,
+			TLSConfig: tlsConfig,
+		},
+		conn:    conn,
+		timeout: g.cfg.ShutdownTimeout,
+	}
+
+	logrus.Infof("Starting HTTP gateway on %s", gatewayAddr)
+	go func() {
+		var err error
+		if tlsConfig != nil {
+			err = gw.httpServer.ListenAndServeTLS("", "")
+		} else {
+			err = gw.httpServer.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
+			logrus.Errorf("Failed to start HTTP gateway on %s, error: %s", gatewayAddr, err)
+		}
+	}()
+
+	return gw, nil
+}
+
+// stop shutdown the HTTP listener then close the in-process connection, it is no-op for the nil gateway
+func (gw *gatewayServer) stop() {
+	if gw == nil {
+		return
+	}
+
+	timeout := gw.timeout
+	if timeout == 0 {
+		timeout = defaultShutdownTimeout
+	}
+
+	logrus.Infoln("Trying to terminate HTTP gateway")
+	ctx, cancel := context.WithTimeout(context.Background(), seconds(timeout))
+	defer cancel()
+
+	if err := gw.httpServer.Shutdown(ctx); err != nil {
+		logrus.Infof("Force shutdown HTTP gateway, error: %s", err)
+	}
+	_ = gw.conn.Close()
+	logrus.Infoln("Successfully terminate HTTP gateway")
+}
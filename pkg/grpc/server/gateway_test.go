@@ -0,0 +1,216 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/marprin/postman-lib/pkg/requestid"
+	"github.com/marprin/postman-lib/proto/email"
+	"github.com/marprin/postman-lib/shared/constants"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type testEmailServer struct {
+	email.UnimplementedEmailServiceServer
+}
+
+func (testEmailServer) CreateEmail(ctx context.Context, in *email.Email) (*email.CreateEmailResponse, error) {
+	if in.ToEmail == "" {
+		return nil, status.Error(codes.InvalidArgument, "to_email is required")
+	}
+	if in.ToEmail == "invalid" {
+		return nil, constants.ErrToEmailIsNotValid
+	}
+	return &email.CreateEmailResponse{Id: "email-1", Status: email.EmailStatus_EMAIL_STATUS_SENT}, nil
+}
+
+func newTestGateway(t *testing.T, cfg *GRPCConfig) http.Handler {
+	inst := NewGrpcServer(cfg, func(s *grpc.Server) {
+		email.RegisterEmailServiceServer(s, testEmailServer{})
+		s.RegisterService(&testServiceDesc, struct{}{})
+	}).(*grpcServer)
+
+	s, err := inst.buildServer(false)
+	assert.Nil(t, err)
+	conn, err := newInProcessConn(s)
+	assert.Nil(t, err)
+	t.Cleanup(func() {
+		conn.Close()
+		s.Stop()
+	})
+
+	return newGateway(s, conn, 1024)
+}
+
+func Test_Gateway(t *testing.T) {
+	const path = "/postman.email.EmailService/CreateEmail"
+	cfg := &GRPCConfig{AuthenticationType: AuthenticationTypeClientSecretKey, ClientKey: "client", SecretKey: "secret"}
+	handler := newTestGateway(t, cfg)
+
+	serve := func(method, path, body string, headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+	auth := map[string]string{AuthorizationHeader: EncodeClientSecretKey("client", "secret")}
+
+	errorMessage := func(rec *httptest.ResponseRecorder) string {
+		var body struct {
+			Errors []struct {
+				Message string `json:"message"`
+			} `json:"errors"`
+		}
+		assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), &body))
+		assert.Len(t, body.Errors, 1)
+		return body.Errors[0].Message
+	}
+
+	t.Run("should transcode the json request and response", func(t *testing.T) {
+		headers := map[string]string{AuthorizationHeader: auth[AuthorizationHeader], requestid.MetadataKey: "gateway-req"}
+		rec := serve(http.MethodPost, path, `{"subject":"Welcome","to_email":"someone@gmail.com"}`, headers)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"id":"email-1","status":"EMAIL_STATUS_SENT","created_at":""}`, rec.Body.String())
+		assert.Equal(t, "gateway-req", rec.Header().Get(requestid.MetadataKey))
+	})
+
+	t.Run("should apply the authentication", func(t *testing.T) {
+		rec := serve(http.MethodPost, path, `{"to_email":"someone@gmail.com"}`, nil)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		assert.Equal(t, ErrAuthorizationTokenIsNotPresent, errorMessage(rec))
+	})
+
+	t.Run("should map the grpc error into the response envelope", func(t *testing.T) {
+		rec := serve(http.MethodPost, path, `{}`, auth)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Equal(t, "to_email is required", errorMessage(rec))
+	})
+
+	t.Run("should reject invalid body", func(t *testing.T) {
+		rec := serve(http.MethodPost, path, `{"unknown":1}`, auth)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Equal(t, ErrGatewayRequestBodyIsInvalid, errorMessage(rec))
+	})
+
+	t.Run("should reject the body larger than the max message size", func(t *testing.T) {
+		body := `{"subject":"` + strings.Repeat("a", 1024) + `","to_email":"someone@gmail.com"}`
+		rec := serve(http.MethodPost, path, body, auth)
+		assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+		assert.Equal(t, ErrGatewayRequestBodyIsTooLarge, errorMessage(rec))
+	})
+
+	t.Run("should reject non POST method", func(t *testing.T) {
+		rec := serve(http.MethodGet, path, "", auth)
+		assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+	})
+
+	t.Run("should not expose unknown and internal method", func(t *testing.T) {
+		for _, p := range []string{"/postman.email.EmailService/Unknown", "/grpc.health.v1.Health/Check", testUnaryMethod} {
+			rec := serve(http.MethodPost, p, `{}`, auth)
+			assert.Equal(t, http.StatusNotFound, rec.Code, p)
+		}
+	})
+}
+
+// freePort return the free local TCP port for the gateway
+func freePort(t *testing.T) uint {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer lis.Close()
+
+	return uint(lis.Addr().(*net.TCPAddr).Port)
+}
+
+func Test_RunContextGateway(t *testing.T) {
+	port := freePort(t)
+	registered := 0
+	inst := NewGrpcServer(&GRPCConfig{Host: "127.0.0.1", MetricHost: "127.0.0.1", GatewayHost: "127.0.0.1", GatewayPort: port, MetricGracefulTimeout: 1},
+		func(s *grpc.Server) {
+			registered++
+			email.RegisterEmailServiceServer(s, testEmailServer{})
+		})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errChan := make(chan error, 1)
+	go func() { errChan <- inst.RunContext(ctx) }()
+
+	url := fmt.Sprintf("http://127.0.0.1:%d/postman.email.EmailService/CreateEmail", port)
+	var resp *http.Response
+	var err error
+	for i := 0; i < 50; i++ {
+		if resp, err = http.Post(url, "application/json", strings.NewReader(`{"to_email":"someone@gmail.com"}`)); err == nil {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	resp.Body.Close()
+
+	cancel()
+	assert.Nil(t, <-errChan)
+	assert.Equal(t, 1, registered)
+}
+
+func Test_RunContextGatewayTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCert(t, "postman-ca", nil, true)
+	caFile, _ := ca.write(t, dir, "ca")
+	serverCertFile, serverKeyFile := newTestCert(t, "localhost", ca, false).write(t, dir, "server")
+	client := newTestCert(t, "email-service", ca, false)
+
+	caPool := x509.NewCertPool()
+	caPool.AddCert(ca.cert)
+
+	port := freePort(t)
+	inst := NewGrpcServer(&GRPCConfig{
+		Host: "127.0.0.1", MetricHost: "127.0.0.1", GatewayHost: "127.0.0.1", GatewayPort: port, MetricGracefulTimeout: 1,
+		UseTLS: true, ServerCertFile: serverCertFile, ServerKeyFile: serverKeyFile, ClientCAFile: caFile,
+	}, func(s *grpc.Server) { email.RegisterEmailServiceServer(s, testEmailServer{}) })
+	runUntilReady(t, inst)
+
+	post := func(certs []tls.Certificate) (*http.Response, error) {
+		httpClient := &http.Client{Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{ServerName: "localhost", RootCAs: caPool, Certificates: certs},
+		}}
+		url := fmt.Sprintf("https://127.0.0.1:%d/postman.email.EmailService/CreateEmail", port)
+
+		var resp *http.Response
+		var err error
+		for i := 0; i < 50; i++ {
+			resp, err = httpClient.Post(url, "application/json", strings.NewReader(`{"to_email":"someone@gmail.com"}`))
+			if err == nil || !strings.Contains(err.Error(), "connection refused") {
+				break
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+		return resp, err
+	}
+
+	t.Run("should serve the gateway with the client certificate", func(t *testing.T) {
+		resp, err := post([]tls.Certificate{client.tlsCertificate()})
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		resp.Body.Close()
+	})
+
+	t.Run("should reject the gateway request without the client certificate", func(t *testing.T) {
+		_, err := post(nil)
+		assert.NotNil(t, err)
+	})
+}
@@ -0,0 +1,65 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/marprin/postman-lib/pkg/healthcheck"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// updateHealthStatus set the serving status of every checker as the service in the health server,
+// the overall "" service is SERVING only when every checker is healthy
+func (g *grpcServer) updateHealthStatus(results []healthcheck.Result) {
+	for _, result := range results {
+		g.healthServer.SetServingStatus(result.Name, servingStatus(result.Healthy))
+	}
+	g.healthServer.SetServingStatus("", servingStatus(g.healthRegistry.Healthy()))
+}
+
+func servingStatus(healthy bool) healthpb.HealthCheckResponse_ServingStatus {
+	if healthy {
+		return healthpb.HealthCheckResponse_SERVING
+	}
+
+	return healthpb.HealthCheckResponse_NOT_SERVING
+}
+
+// startHealthRegistry run the health checks on interval and reflect the results into the health server
+func (g *grpcServer) startHealthRegistry(ctx context.Context) {
+	if g.healthRegistry == nil {
+		return
+	}
+
+	g.healthRegistry.Subscribe(g.updateHealthStatus)
+	go g.healthRegistry.Start(ctx)
+}
+
+// readinessHandler respond 503 while the server is draining or any checker is unhealthy
+func (g *grpcServer) readinessHandler() http.Handler {
+	var registryHandler http.Handler = healthcheck.LivenessHandler()
+	if g.healthRegistry != nil {
+		registryHandler = g.healthRegistry.ReadinessHandler()
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&g.draining) == 1 {
+			http.Error(w, "shutting down", http.StatusServiceUnavailable)
+			return
+		}
+		registryHandler.ServeHTTP(w, r)
+	})
+}
+
+// metricsHandler serve the liveness and readiness probes next to the prometheus metrics
+func (g *grpcServer) metricsHandler(promRegistry *prometheus.Registry) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/", promhttp.HandlerFor(promRegistry, promhttp.HandlerOpts{}))
+	mux.Handle("/healthz", healthcheck.LivenessHandler())
+	mux.Handle("/readyz", g.readinessHandler())
+
+	return mux
+}
@@ -0,0 +1,63 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/marprin/postman-lib/pkg/healthcheck"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func Test_HealthRegistry(t *testing.T) {
+	registry := healthcheck.NewRegistry(0, 0)
+	registry.Register("mysql", healthcheck.CheckerFunc(func(context.Context) error { return nil }))
+	registry.Register("redis", healthcheck.CheckerFunc(func(context.Context) error { return errors.New("down") }))
+
+	inst := NewGrpcServer(&GRPCConfig{}, nil, WithHealthRegistry(registry)).(*grpcServer)
+	inst.startHealthRegistry(context.Background())
+	registry.RunChecks(context.Background())
+
+	t.Run("should set the serving status per checker", func(t *testing.T) {
+		checks := map[string]healthpb.HealthCheckResponse_ServingStatus{
+			"mysql": healthpb.HealthCheckResponse_SERVING,
+			"redis": healthpb.HealthCheckResponse_NOT_SERVING,
+			"":      healthpb.HealthCheckResponse_NOT_SERVING,
+		}
+		for service, expected := range checks {
+			resp, err := inst.healthServer.Check(context.Background(), &healthpb.HealthCheckRequest{Service: service})
+			assert.Nil(t, err)
+			assert.Equal(t, expected, resp.Status, service)
+		}
+	})
+
+	t.Run("should serve the probes on the metrics handler", func(t *testing.T) {
+		handler := inst.metricsHandler(prometheus.NewRegistry())
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		rec = httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	})
+
+	t.Run("should not be ready while draining", func(t *testing.T) {
+		inst := NewGrpcServer(&GRPCConfig{}, nil).(*grpcServer)
+		handler := inst.metricsHandler(prometheus.NewRegistry())
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		inst.draining = 1
+		rec = httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	})
+}
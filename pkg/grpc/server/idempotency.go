@@ -0,0 +1,235 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/marprin/postman-lib/pkg/rpcmethod"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+type (
+	// IdempotencyStore keep the response of the idempotent request, *redis.Store of pkg/redis implement it
+	IdempotencyStore interface {
+		SetNX(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error)
+		Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+		Get(ctx context.Context, key string) ([]byte, error)
+		Del(ctx context.Context, key string) error
+	}
+
+	idempotency struct {
+		store   IdempotencyStore
+		ttl     time.Duration
+		lockTTL time.Duration
+		methods []string
+	}
+)
+
+const (
+	// IdempotencyKeyMetadataKey is the metadata key of the idempotency key sent by the client
+	IdempotencyKeyMetadataKey = "idempotency-key"
+	// IdempotentReplayedHeader is set on the response header when the stored response is returned
+	IdempotentReplayedHeader = "idempotent-replayed"
+
+	// idempotencyLockTTL is the TTL of the pending marker, it bound how long the key stay reserved
+	// when the server crash while handling the request. The marker is renewed while the handler run
+	// and the TTL is extended when the response is stored
+	idempotencyLockTTL = 30 * time.Second
+	// idempotencyReserveAttempts is the number of SetNX attempts when the reserved key expire before Get
+	idempotencyReserveAttempts = 3
+)
+
+var (
+	ErrIdempotentRequestInProgress = "Request with the same idempotency key is in progress"
+
+	// idempotencyPending is stored while the first request is in progress, it is never a valid anypb.Any
+	idempotencyPending = []byte("\x00pending")
+)
+
+// newIdempotency create the idempotency with the lock TTL which is not longer than the TTL
+func newIdempotency(store IdempotencyStore, ttl time.Duration, methods []string) *idempotency {
+	lockTTL := idempotencyLockTTL
+	if ttl < lockTTL {
+		lockTTL = ttl
+	}
+
+	return &idempotency{store: store, ttl: ttl, lockTTL: lockTTL, methods: methods}
+}
+
+// applies return true when the method is configured to be idempotent, every method when no method is configured
+func (i *idempotency) applies(method string) bool {
+	if len(i.methods) == 0 {
+		return true
+	}
+
+	for _, pattern := range i.methods {
+		if rpcmethod.Match(pattern, method) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func idempotencyKey(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok || len(md[IdempotencyKeyMetadataKey]) == 0 {
+		return ""
+	}
+
+	return md[IdempotencyKeyMetadataKey][0]
+}
+
+// idempotent call the handler once for the client, method and idempotency key, the repeated call
+// get the stored response and the concurrent duplicate get Aborted while the first is in progress.
+// The store failure does not fail the request, the handler is called without the idempotency
+func (g *grpcServer) idempotent(ctx context.Context, method string, req interface{}, handler grpc.UnaryHandler) (interface{}, error) {
+	key := idempotencyKey(ctx)
+	if g.idempotency == nil || key == "" || !g.idempotency.applies(method) {
+		return handler(ctx, req)
+	}
+
+	client, _ := ClientIDFromContext(ctx)
+	storeKey := fmt.Sprintf("idempotency:%s:%s:%s", client, method, key)
+	store := g.idempotency.store
+
+	reserved, stored, err := g.reserveIdempotencyKey(ctx, storeKey)
+	if err != nil {
+		logrus.WithError(err).Warnln("Failed to reserve the idempotency key, handle the request without it")
+		return handler(ctx, req)
+	}
+
+	if !reserved {
+		if stored == nil || bytes.Equal(stored, idempotencyPending) {
+			return nil, status.Error(codes.Aborted, ErrIdempotentRequestInProgress)
+		}
+
+		resp, err := unmarshalIdempotentResponse(stored)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "%s", err)
+		}
+		_ = grpc.SetHeader(ctx, metadata.Pairs(IdempotentReplayedHeader, "true"))
+		return resp, nil
+	}
+
+	// release the key unless the response is stored, so the client can retry the failed
+	// or panicked request, the deferred release also run while the panic is unwinding
+	completed := false
+	defer func() {
+		if completed {
+			return
+		}
+		if err := store.Del(context.Background(), storeKey); err != nil {
+			logrus.WithError(err).Warnln("Failed to release the idempotency key")
+		}
+	}()
+
+	// keep the key reserved while the handler run longer than the lock TTL, the renewal is stopped
+	// before the key is released or the response is stored
+	stopRenew := g.idempotency.keepReserved(storeKey)
+	defer stopRenew()
+
+	resp, err := handler(ctx, req)
+	stopRenew()
+	if err != nil {
+		return resp, err
+	}
+
+	if err := g.storeIdempotentResponse(storeKey, resp); err != nil {
+		logrus.WithError(err).Warnln("Failed to store the idempotent response")
+		return resp, nil
+	}
+	completed = true
+
+	return resp, nil
+}
+
+// reserveIdempotencyKey store the pending marker with the short lock TTL, it return the stored value
+// when the key is already taken. The key which expire between SetNX and Get is reserved again
+func (g *grpcServer) reserveIdempotencyKey(ctx context.Context, storeKey string) (bool, []byte, error) {
+	store := g.idempotency.store
+
+	for attempt := 0; attempt < idempotencyReserveAttempts; attempt++ {
+		reserved, err := store.SetNX(ctx, storeKey, idempotencyPending, g.idempotency.lockTTL)
+		if err != nil || reserved {
+			return reserved, nil, err
+		}
+
+		stored, err := store.Get(ctx, storeKey)
+		if err != nil || stored != nil {
+			return false, stored, err
+		}
+	}
+
+	return false, nil, nil
+}
+
+// keepReserved renew the pending marker on every half of the lock TTL until the returned stop is called,
+// the stop wait for the running renewal so it never overwrite the stored response
+func (i *idempotency) keepReserved(storeKey string) func() {
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		ticker := time.NewTicker(i.lockTTL / 2)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := i.store.Set(context.Background(), storeKey, idempotencyPending, i.lockTTL); err != nil {
+					logrus.WithError(err).Warnln("Failed to renew the idempotency key")
+				}
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+			wg.Wait()
+		})
+	}
+}
+
+// storeIdempotentResponse replace the pending marker with the response wrapped in anypb.Any
+func (g *grpcServer) storeIdempotentResponse(storeKey string, resp interface{}) error {
+	m, ok := resp.(proto.Message)
+	if !ok {
+		return fmt.Errorf("response %T is not a proto message", resp)
+	}
+
+	wrapped, err := anypb.New(m)
+	if err != nil {
+		return err
+	}
+
+	stored, err := proto.Marshal(wrapped)
+	if err != nil {
+		return err
+	}
+
+	return g.idempotency.store.Set(context.Background(), storeKey, stored, g.idempotency.ttl)
+}
+
+func unmarshalIdempotentResponse(stored []byte) (proto.Message, error) {
+	wrapped := &anypb.Any{}
+	if err := proto.Unmarshal(stored, wrapped); err != nil {
+		return nil, err
+	}
+
+	return wrapped.UnmarshalNew()
+}
@@ -0,0 +1,289 @@
+package server
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+type memoryStore struct {
+	mu      sync.Mutex
+	values  map[string][]byte
+	ttls    map[string]time.Duration
+	expires map[string]time.Time
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{values: map[string][]byte{}, ttls: map[string]time.Duration{}, expires: map[string]time.Time{}}
+}
+
+// expire delete the key which outlive its TTL, the caller must hold the lock
+func (s *memoryStore) expire(key string) {
+	if expiresAt, ok := s.expires[key]; ok && time.Now().After(expiresAt) {
+		delete(s.values, key)
+		delete(s.ttls, key)
+		delete(s.expires, key)
+	}
+}
+
+// set store the value with the TTL, the caller must hold the lock
+func (s *memoryStore) set(key string, value []byte, ttl time.Duration) {
+	s.values[key] = value
+	s.ttls[key] = ttl
+	s.expires[key] = time.Now().Add(ttl)
+}
+
+func (s *memoryStore) SetNX(_ context.Context, key string, value []byte, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.expire(key)
+	if _, ok := s.values[key]; ok {
+		return false, nil
+	}
+	s.set(key, value, ttl)
+	return true, nil
+}
+
+func (s *memoryStore) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.set(key, value, ttl)
+	return nil
+}
+
+func (s *memoryStore) ttl(key string) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.ttls[key]
+}
+
+// expiringStore expire the key on the first Get, like the pending marker which expire between SetNX and Get
+type expiringStore struct {
+	*memoryStore
+	expired bool
+}
+
+func (s *expiringStore) Get(ctx context.Context, key string) ([]byte, error) {
+	if !s.expired {
+		s.expired = true
+		_ = s.Del(ctx, key)
+	}
+
+	return s.memoryStore.Get(ctx, key)
+}
+
+func (s *memoryStore) Get(_ context.Context, key string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.expire(key)
+	return s.values[key], nil
+}
+
+func (s *memoryStore) Del(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.values, key)
+	delete(s.ttls, key)
+	delete(s.expires, key)
+	return nil
+}
+
+func Test_Idempotency(t *testing.T) {
+	var mu sync.Mutex
+	calls := 0
+	entered := make(chan struct{})
+	block := make(chan struct{})
+	store := newMemoryStore()
+	conn := dialTestServer(t, &GRPCConfig{},
+		WithIdempotency(store, time.Hour, testUnaryMethod),
+		WithUnaryInterceptors(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			mu.Lock()
+			calls++
+			mu.Unlock()
+			switch req.(*wrapperspb.StringValue).Value {
+			case "block":
+				close(entered)
+				<-block
+			case "fail":
+				return nil, status.Error(codes.Unavailable, "unavailable")
+			case "panic":
+				panic("handler panic")
+			}
+			return handler(ctx, req)
+		}),
+	)
+	callCount := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return calls
+	}
+	invoke := func(key, value string, opts ...grpc.CallOption) (*wrapperspb.StringValue, error) {
+		ctx := context.Background()
+		if key != "" {
+			ctx = metadata.AppendToOutgoingContext(ctx, IdempotencyKeyMetadataKey, key)
+		}
+		out := &wrapperspb.StringValue{}
+		err := conn.Invoke(ctx, testUnaryMethod, wrapperspb.String(value), out, opts...)
+		return out, err
+	}
+
+	t.Run("should return the stored response for the repeated key", func(t *testing.T) {
+		calls = 0
+		out, err := invoke("key-1", "first")
+		assert.Nil(t, err)
+		assert.Equal(t, "first", out.Value)
+
+		var header metadata.MD
+		out, err = invoke("key-1", "second", grpc.Header(&header))
+		assert.Nil(t, err)
+		assert.Equal(t, "first", out.Value)
+		assert.Equal(t, []string{"true"}, header.Get(IdempotentReplayedHeader))
+		assert.Equal(t, 1, callCount())
+		assert.Equal(t, time.Hour, store.ttl("idempotency::"+testUnaryMethod+":key-1"))
+	})
+
+	t.Run("should call the handler without the key", func(t *testing.T) {
+		calls = 0
+		_, _ = invoke("", "hello")
+		_, _ = invoke("", "hello")
+		assert.Equal(t, 2, callCount())
+	})
+
+	t.Run("should release the key of the failed request", func(t *testing.T) {
+		calls = 0
+		_, err := invoke("key-2", "fail")
+		assert.Equal(t, codes.Unavailable, status.Code(err))
+
+		out, err := invoke("key-2", "hello")
+		assert.Nil(t, err)
+		assert.Equal(t, "hello", out.Value)
+		assert.Equal(t, 2, callCount())
+	})
+
+	t.Run("should release the key of the panicked request", func(t *testing.T) {
+		calls = 0
+		_, err := invoke("key-4", "panic")
+		assert.Equal(t, codes.Internal, status.Code(err))
+
+		out, err := invoke("key-4", "hello")
+		assert.Nil(t, err)
+		assert.Equal(t, "hello", out.Value)
+		assert.Equal(t, 2, callCount())
+	})
+
+	t.Run("should abort the concurrent duplicate", func(t *testing.T) {
+		done := make(chan error, 1)
+		go func() {
+			_, err := invoke("key-3", "block")
+			done <- err
+		}()
+
+		<-entered
+		_, err := invoke("key-3", "block")
+		assert.Equal(t, codes.Aborted, status.Code(err))
+		assert.Equal(t, idempotencyLockTTL, store.ttl("idempotency::"+testUnaryMethod+":key-3"))
+
+		close(block)
+		assert.Nil(t, <-done)
+	})
+}
+
+func Test_IdempotencyLongRequest(t *testing.T) {
+	t.Run("should abort the duplicate of the request which outlive the lock TTL", func(t *testing.T) {
+		var mu sync.Mutex
+		calls := 0
+		entered := make(chan struct{})
+		block := make(chan struct{})
+		store := newMemoryStore()
+		conn := dialTestServer(t, &GRPCConfig{},
+			WithIdempotency(store, time.Hour, testUnaryMethod),
+			func(g *grpcServer) { g.idempotency.lockTTL = 20 * time.Millisecond },
+			WithUnaryInterceptors(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+				mu.Lock()
+				calls++
+				first := calls == 1
+				mu.Unlock()
+				if first {
+					close(entered)
+					<-block
+				}
+				return handler(ctx, req)
+			}),
+		)
+		invoke := func() (*wrapperspb.StringValue, error) {
+			ctx := metadata.AppendToOutgoingContext(context.Background(), IdempotencyKeyMetadataKey, "slow")
+			out := &wrapperspb.StringValue{}
+			err := conn.Invoke(ctx, testUnaryMethod, wrapperspb.String("slow"), out)
+			return out, err
+		}
+
+		done := make(chan error, 1)
+		go func() {
+			_, err := invoke()
+			done <- err
+		}()
+
+		<-entered
+		time.Sleep(100 * time.Millisecond)
+		_, err := invoke()
+		assert.Equal(t, codes.Aborted, status.Code(err))
+
+		close(block)
+		assert.Nil(t, <-done)
+
+		out, err := invoke()
+		assert.Nil(t, err)
+		assert.Equal(t, "slow", out.Value)
+		assert.Equal(t, time.Hour, store.ttl("idempotency::"+testUnaryMethod+":slow"))
+
+		mu.Lock()
+		defer mu.Unlock()
+		assert.Equal(t, 1, calls)
+	})
+}
+
+func Test_reserveIdempotencyKey(t *testing.T) {
+	t.Run("should reserve the key again when it expire before Get", func(t *testing.T) {
+		store := &expiringStore{memoryStore: newMemoryStore()}
+		_ = store.Set(context.Background(), "key", idempotencyPending, time.Minute)
+		g := &grpcServer{idempotency: newIdempotency(store, time.Minute, nil)}
+
+		reserved, stored, err := g.reserveIdempotencyKey(context.Background(), "key")
+		assert.Nil(t, err)
+		assert.True(t, reserved)
+		assert.Nil(t, stored)
+	})
+
+	t.Run("should use the TTL as the lock TTL when it is shorter", func(t *testing.T) {
+		store := newMemoryStore()
+		g := &grpcServer{idempotency: newIdempotency(store, time.Second, nil)}
+
+		reserved, _, err := g.reserveIdempotencyKey(context.Background(), "key")
+		assert.Nil(t, err)
+		assert.True(t, reserved)
+		assert.Equal(t, time.Second, store.ttl("key"))
+	})
+}
+
+func Test_WithIdempotency(t *testing.T) {
+	t.Run("should not enable the idempotency with non positive TTL", func(t *testing.T) {
+		for _, ttl := range []time.Duration{0, -time.Second} {
+			g := &grpcServer{}
+			WithIdempotency(newMemoryStore(), ttl)(g)
+			assert.Nil(t, g.idempotency)
+		}
+	})
+}
@@ -0,0 +1,170 @@
+package server
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	rpcerrors "github.com/marprin/postman-lib/pkg/errors"
+	"github.com/marprin/postman-lib/pkg/panic"
+	"github.com/marprin/postman-lib/pkg/redact"
+	"github.com/marprin/postman-lib/pkg/requestid"
+	"github.com/opentracing/opentracing-go"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// RetryAttemptMetadataKey is the attempt number sent by the retry interceptor of pkg/grpc/client
+	RetryAttemptMetadataKey = "x-retry-attempt"
+)
+
+type (
+	// authenticateFunc validate the incoming request and return the context enriched with the authenticated identity
+	authenticateFunc func(ctx context.Context, method string) (context.Context, error)
+)
+
+// isHealthCheck return true for the health check probe which skip the authentication and logging
+func isHealthCheck(method string) bool {
+	return strings.HasPrefix(method, "/grpc.health")
+}
+
+func (g *grpcServer) unaryInterceptorHandler(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+	timeStart := time.Now()
+	method := info.FullMethod
+	// handle any panic ocured on the server
+	defer panic.HandlePanic(func(r interface{}) {
+		panic.ToPanicError(r, info.FullMethod)
+		err = status.Errorf(codes.Internal, "%s", r)
+	})
+
+	// skip loging health check probe
+	if isHealthCheck(method) {
+		return handler(ctx, req)
+	}
+
+	ctx = withRequestID(ctx)
+	_ = grpc.SetHeader(ctx, requestIDHeader(ctx))
+
+	ctx, cancel, err := g.withDeadline(ctx, method)
+	if err != nil {
+		return nil, err
+	}
+	defer cancel()
+	defer g.countDeadlineExceeded(ctx, method)
+
+	ctx, err = g.authorize(ctx, method)
+	if err != nil {
+		return nil, err
+	}
+
+	release, retryAfter, err := g.limit(ctx, method)
+	if err != nil {
+		_ = grpc.SetTrailer(ctx, retryAfterTrailer(retryAfter))
+		return nil, err
+	}
+	defer release()
+
+	redactor := g.payloadRedactor()
+	sampled := redactor.Sampled(method)
+
+	LogUnaryRequest(ctx, method, loggablePayload(redactor, sampled, req))
+	resp, err = g.idempotent(ctx, method, req, handler)
+	err = rpcerrors.Convert(err)
+	LogUnaryResponse(ctx, method, timeStart, loggablePayload(redactor, sampled, resp), err)
+
+	return resp, err
+}
+
+// withRequestID take the request ID from the incoming metadata or generate a new one,
+// then put it into the context and the active tracing span
+func withRequestID(ctx context.Context) context.Context {
+	var reqID string
+	if md, ok := metadata.FromIncomingContext(ctx); ok && len(md[requestid.MetadataKey]) > 0 {
+		reqID = md[requestid.MetadataKey][0]
+	}
+	reqID = requestid.Sanitize(reqID)
+
+	if span := opentracing.SpanFromContext(ctx); span != nil {
+		span.SetTag("request_id", reqID)
+	}
+
+	return requestid.NewContext(ctx, reqID)
+}
+
+// requestIDHeader return the response header which echo the request ID back to the caller
+func requestIDHeader(ctx context.Context) metadata.MD {
+	reqID, _ := requestid.FromContext(ctx)
+	return metadata.Pairs(requestid.MetadataKey, reqID)
+}
+
+// RequestIDFromContext return the request ID of the current request
+func RequestIDFromContext(ctx context.Context) (string, bool) {
+	return requestid.FromContext(ctx)
+}
+
+// payloadRedactor return the redactor of the server, or the shared default redactor
+func (g *grpcServer) payloadRedactor() *redact.Redactor {
+	if g.redactor != nil {
+		return g.redactor
+	}
+
+	return redact.Default()
+}
+
+// loggablePayload return the redacted payload, nil when the payload of the request is not sampled
+func loggablePayload(redactor *redact.Redactor, sampled bool, payload interface{}) interface{} {
+	if !sampled {
+		return nil
+	}
+
+	return redactor.Redact(payload)
+}
+
+// contextLogFields return the log fields taken from the request context
+func contextLogFields(ctx context.Context) logrus.Fields {
+	fields := logrus.Fields{}
+	if reqID, ok := requestid.FromContext(ctx); ok {
+		fields["req_id"] = reqID
+	}
+
+	if clientID, ok := ClientIDFromContext(ctx); ok {
+		fields["client_id"] = clientID
+	}
+
+	if md, ok := metadata.FromIncomingContext(ctx); ok && len(md[RetryAttemptMetadataKey]) > 0 {
+		fields["attempt"] = md[RetryAttemptMetadataKey][0]
+	}
+
+	return fields
+}
+
+// LogUnaryRequest log the incoming request, req is omitted from the log when it is nil
+func LogUnaryRequest(ctx context.Context, method string, req interface{}) {
+	fields := contextLogFields(ctx)
+	fields["method"] = method
+	if req != nil {
+		fields["req"] = req
+	}
+
+	logrus.WithFields(fields).Info("incoming rpc unary request")
+}
+
+// LogUnaryResponse log the result of the request, resp is omitted from the log when it is nil
+func LogUnaryResponse(ctx context.Context, method string, timeStart time.Time, resp interface{}, err error) {
+	fields := contextLogFields(ctx)
+	fields["method"] = method
+	fields["took"] = time.Since(timeStart)
+
+	if err != nil {
+		logrus.WithFields(fields).WithError(err).Error("rpc unary request failed")
+	} else {
+		if resp != nil {
+			fields["resp"] = resp
+		}
+		logrus.WithFields(fields).Info("rpc unary request succeeded")
+	}
+}
@@ -0,0 +1,380 @@
+package server
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+type (
+	// Claims is the identity of the authenticated caller, Raw is only filled for the JWT bearer token
+	Claims struct {
+		Subject   string
+		Issuer    string
+		Audience  []string
+		ExpiresAt time.Time
+		NotBefore time.Time
+		Scopes    []string
+		Roles     []string
+		Raw       map[string]interface{}
+	}
+
+	jwtHeader struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+
+	jwk struct {
+		Kty string `json:"kty"`
+		Kid string `json:"kid"`
+		Crv string `json:"crv"`
+		N   string `json:"n"`
+		E   string `json:"e"`
+		X   string `json:"x"`
+		Y   string `json:"y"`
+	}
+
+	jwks struct {
+		Keys []jwk `json:"keys"`
+	}
+
+	publicKey struct {
+		kid string
+		key crypto.PublicKey
+	}
+
+	// jwtVerifier validate the signature and the registered claims of the bearer token
+	jwtVerifier struct {
+		secret   []byte
+		keys     []publicKey
+		issuer   string
+		audience string
+		// allowMissingExp accept the token without exp, it is never expired
+		allowMissingExp bool
+		now             func() time.Time
+	}
+)
+
+const (
+	// AuthenticationTypeJWT is the authentication with JWT bearer token
+	AuthenticationTypeJWT AuthenticationType = "jwt"
+
+	// BearerAuthorizationHeader is the metadata key for the bearer token
+	BearerAuthorizationHeader string = "authorization"
+
+	claimsContextKey contextKey = "claims"
+
+	// jwtClockSkew is the leeway when validating exp and nbf
+	jwtClockSkew = 30 * time.Second
+)
+
+var (
+	ErrJWTKeyIsRequired      = errors.New("JWT secret or JWKS file is required for JWT authentication")
+	ErrJWKSIsNotValid        = errors.New("JWKS file does not contain any supported key")
+	ErrTokenIsMalformed      = errors.New("token is malformed")
+	ErrTokenAlgIsNotAllowed  = errors.New("token signing algorithm is not allowed")
+	ErrTokenSignatureInvalid = errors.New("token signature is not valid")
+	ErrTokenIsExpired        = errors.New("token is expired")
+	ErrTokenExpIsRequired    = errors.New("token exp is required")
+	ErrTokenIsNotValidYet    = errors.New("token is not valid yet")
+	ErrTokenIssuerInvalid    = errors.New("token issuer is not valid")
+	ErrTokenAudienceInvalid  = errors.New("token audience is not valid")
+)
+
+// ClaimsFromContext return the claims of the authenticated caller of the current request
+func ClaimsFromContext(ctx context.Context) (*Claims, bool) {
+	claims, ok := ctx.Value(claimsContextKey).(*Claims)
+	return claims, ok
+}
+
+// HasScope return true when the claims contain the scope
+func (c *Claims) HasScope(scope string) bool {
+	return containsString(c.Scopes, scope)
+}
+
+// HasRole return true when the claims contain the role
+func (c *Claims) HasRole(role string) bool {
+	return containsString(c.Roles, role)
+}
+
+func newJWTVerifier(cfg *GRPCConfig) (*jwtVerifier, error) {
+	v := &jwtVerifier{
+		issuer:          cfg.JWTIssuer,
+		audience:        cfg.JWTAudience,
+		allowMissingExp: cfg.JWTAllowMissingExp,
+		now:             time.Now,
+	}
+
+	if cfg.JWTSecret != "" {
+		v.secret = []byte(cfg.JWTSecret)
+	}
+
+	if cfg.JWKSFile != "" {
+		keys, err := loadJWKS(cfg.JWKSFile)
+		if err != nil {
+			return nil, err
+		}
+		v.keys = keys
+	}
+
+	if v.secret == nil && len(v.keys) == 0 {
+		return nil, ErrJWTKeyIsRequired
+	}
+
+	return v, nil
+}
+
+// loadJWKS read the RSA and EC P-256 public keys from the local JWKS file
+func loadJWKS(fname string) ([]publicKey, error) {
+	raw, err := ioutil.ReadFile(fname)
+	if err != nil {
+		return nil, err
+	}
+
+	var set jwks
+	if err := json.Unmarshal(raw, &set); err != nil {
+		return nil, err
+	}
+
+	keys := make([]publicKey, 0, len(set.Keys))
+	for _, k := range set.Keys {
+		switch k.Kty {
+		case "RSA":
+			n, errN := base64.RawURLEncoding.DecodeString(k.N)
+			e, errE := base64.RawURLEncoding.DecodeString(k.E)
+			if errN != nil || errE != nil {
+				return nil, ErrJWKSIsNotValid
+			}
+			keys = append(keys, publicKey{kid: k.Kid, key: &rsa.PublicKey{
+				N: new(big.Int).SetBytes(n),
+				E: int(new(big.Int).SetBytes(e).Int64()),
+			}})
+		case "EC":
+			if k.Crv != "P-256" {
+				continue
+			}
+			x, errX := base64.RawURLEncoding.DecodeString(k.X)
+			y, errY := base64.RawURLEncoding.DecodeString(k.Y)
+			if errX != nil || errY != nil {
+				return nil, ErrJWKSIsNotValid
+			}
+			keys = append(keys, publicKey{kid: k.Kid, key: &ecdsa.PublicKey{
+				Curve: elliptic.P256(),
+				X:     new(big.Int).SetBytes(x),
+				Y:     new(big.Int).SetBytes(y),
+			}})
+		}
+	}
+
+	if len(keys) == 0 {
+		return nil, ErrJWKSIsNotValid
+	}
+
+	return keys, nil
+}
+
+// verify check the signature, exp, nbf, iss and aud of the token and return the claims
+func (v *jwtVerifier) verify(token string) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrTokenIsMalformed
+	}
+
+	var header jwtHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, ErrTokenIsMalformed
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrTokenIsMalformed
+	}
+
+	if err := v.verifySignature(header, parts[0]+"."+parts[1], sig); err != nil {
+		return nil, err
+	}
+
+	raw := map[string]interface{}{}
+	if err := decodeSegment(parts[1], &raw); err != nil {
+		return nil, ErrTokenIsMalformed
+	}
+
+	claims := parseClaims(raw)
+	now := v.now()
+	if claims.ExpiresAt.IsZero() && !v.allowMissingExp {
+		return nil, ErrTokenExpIsRequired
+	}
+
+	if !claims.ExpiresAt.IsZero() && now.After(claims.ExpiresAt.Add(jwtClockSkew)) {
+		return nil, ErrTokenIsExpired
+	}
+
+	if !claims.NotBefore.IsZero() && now.Add(jwtClockSkew).Before(claims.NotBefore) {
+		return nil, ErrTokenIsNotValidYet
+	}
+
+	if v.issuer != "" && claims.Issuer != v.issuer {
+		return nil, ErrTokenIssuerInvalid
+	}
+
+	if v.audience != "" && !containsString(claims.Audience, v.audience) {
+		return nil, ErrTokenAudienceInvalid
+	}
+
+	return claims, nil
+}
+
+func (v *jwtVerifier) verifySignature(header jwtHeader, signed string, sig []byte) error {
+	digest := sha256.Sum256([]byte(signed))
+
+	switch header.Alg {
+	case "HS256":
+		if v.secret == nil {
+			return ErrTokenAlgIsNotAllowed
+		}
+		mac := hmac.New(sha256.New, v.secret)
+		mac.Write([]byte(signed))
+		if !hmac.Equal(sig, mac.Sum(nil)) {
+			return ErrTokenSignatureInvalid
+		}
+		return nil
+	case "RS256":
+		for _, k := range v.candidateKeys(header.Kid) {
+			if pub, ok := k.(*rsa.PublicKey); ok && rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest[:], sig) == nil {
+				return nil
+			}
+		}
+		return ErrTokenSignatureInvalid
+	case "ES256":
+		if len(sig) != 64 {
+			return ErrTokenSignatureInvalid
+		}
+		r := new(big.Int).SetBytes(sig[:32])
+		s := new(big.Int).SetBytes(sig[32:])
+		for _, k := range v.candidateKeys(header.Kid) {
+			if pub, ok := k.(*ecdsa.PublicKey); ok && ecdsa.Verify(pub, digest[:], r, s) {
+				return nil
+			}
+		}
+		return ErrTokenSignatureInvalid
+	}
+
+	return ErrTokenAlgIsNotAllowed
+}
+
+// candidateKeys return the key with the matching kid, or every key when the token has no kid
+func (v *jwtVerifier) candidateKeys(kid string) []crypto.PublicKey {
+	keys := make([]crypto.PublicKey, 0, len(v.keys))
+	for _, k := range v.keys {
+		if kid == "" || k.kid == kid {
+			keys = append(keys, k.key)
+		}
+	}
+
+	return keys
+}
+
+func decodeSegment(seg string, v interface{}) error {
+	raw, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(raw, v)
+}
+
+func parseClaims(raw map[string]interface{}) *Claims {
+	claims := &Claims{Raw: raw}
+	claims.Subject, _ = raw["sub"].(string)
+	claims.Issuer, _ = raw["iss"].(string)
+	claims.Audience = stringOrSlice(raw["aud"])
+
+	if exp, ok := raw["exp"].(float64); ok {
+		claims.ExpiresAt = time.Unix(int64(exp), 0)
+	}
+
+	if nbf, ok := raw["nbf"].(float64); ok {
+		claims.NotBefore = time.Unix(int64(nbf), 0)
+	}
+
+	// scope is space separated string, scp is the array form used by some providers
+	if scope, ok := raw["scope"].(string); ok {
+		claims.Scopes = strings.Fields(scope)
+	} else {
+		claims.Scopes = stringOrSlice(raw["scp"])
+	}
+	claims.Roles = stringOrSlice(raw["roles"])
+
+	return claims
+}
+
+func stringOrSlice(v interface{}) []string {
+	switch val := v.(type) {
+	case string:
+		return []string{val}
+	case []interface{}:
+		result := make([]string, 0, len(val))
+		for _, item := range val {
+			if s, ok := item.(string); ok {
+				result = append(result, s)
+			}
+		}
+		return result
+	}
+
+	return nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+
+	return false
+}
+
+// authenticateJWT validate the bearer token from the incoming metadata
+func (g *grpcServer) authenticateJWT(ctx context.Context, method string) (context.Context, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ctx, status.Error(codes.Internal, ErrFailedExtractMetadata)
+	}
+
+	if len(md[BearerAuthorizationHeader]) == 0 {
+		return ctx, status.Error(codes.Unauthenticated, ErrAuthorizationTokenIsNotPresent)
+	}
+
+	token := md[BearerAuthorizationHeader][0]
+	if len(token) < 7 || !strings.EqualFold(token[:7], "bearer ") {
+		return ctx, status.Error(codes.Unauthenticated, ErrAuthorizationTokenIsNotValid)
+	}
+
+	claims, err := g.jwtVerifier.verify(strings.TrimSpace(token[7:]))
+	if err != nil {
+		return ctx, status.Errorf(codes.Unauthenticated, "%s: %s", ErrAuthorizationTokenIsNotValid, err)
+	}
+
+	ctx = context.WithValue(ctx, claimsContextKey, claims)
+	if claims.Subject != "" {
+		ctx = context.WithValue(ctx, clientIDContextKey, claims.Subject)
+	}
+
+	return ctx, nil
+}
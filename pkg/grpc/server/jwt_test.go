@@ -0,0 +1,222 @@
+package server
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func signTestJWT(t *testing.T, alg, kid string, key interface{}, claims map[string]interface{}) string {
+	header, err := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	assert.Nil(t, err)
+	payload, err := json.Marshal(claims)
+	assert.Nil(t, err)
+
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+
+	var sig []byte
+	switch alg {
+	case "HS256":
+		mac := hmac.New(sha256.New, key.([]byte))
+		mac.Write([]byte(signed))
+		sig = mac.Sum(nil)
+	case "RS256":
+		sig, err = rsa.SignPKCS1v15(rand.Reader, key.(*rsa.PrivateKey), crypto.SHA256, digest[:])
+		assert.Nil(t, err)
+	case "ES256":
+		r, s, err := ecdsa.Sign(rand.Reader, key.(*ecdsa.PrivateKey), digest[:])
+		assert.Nil(t, err)
+		sig = make([]byte, 64)
+		r.FillBytes(sig[:32])
+		s.FillBytes(sig[32:])
+	}
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func writeTestJWKS(t *testing.T, rsaKey *rsa.PrivateKey, ecKey *ecdsa.PrivateKey) string {
+	set := map[string]interface{}{
+		"keys": []map[string]string{
+			{
+				"kty": "RSA",
+				"kid": "rsa-key",
+				"n":   base64.RawURLEncoding.EncodeToString(rsaKey.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(rsaKey.E)).Bytes()),
+			},
+			{
+				"kty": "EC",
+				"kid": "ec-key",
+				"crv": "P-256",
+				"x":   base64.RawURLEncoding.EncodeToString(ecKey.X.Bytes()),
+				"y":   base64.RawURLEncoding.EncodeToString(ecKey.Y.Bytes()),
+			},
+		},
+	}
+	raw, err := json.Marshal(set)
+	assert.Nil(t, err)
+
+	fname := filepath.Join(t.TempDir(), "jwks.json")
+	assert.Nil(t, ioutil.WriteFile(fname, raw, 0600))
+	return fname
+}
+
+func Test_jwtVerifier(t *testing.T) {
+	secret := []byte("shared-secret")
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+
+	v, err := newJWTVerifier(&GRPCConfig{
+		JWTSecret:   string(secret),
+		JWKSFile:    writeTestJWKS(t, rsaKey, ecKey),
+		JWTIssuer:   "postman-auth",
+		JWTAudience: "postman",
+	})
+	assert.Nil(t, err)
+
+	validClaims := func() map[string]interface{} {
+		return map[string]interface{}{
+			"sub":   "email-service",
+			"iss":   "postman-auth",
+			"aud":   []string{"postman", "other"},
+			"exp":   time.Now().Add(time.Hour).Unix(),
+			"nbf":   time.Now().Add(-time.Minute).Unix(),
+			"scope": "email:send sms:send",
+		}
+	}
+
+	t.Run("should verify HS256, RS256 and ES256 token", func(t *testing.T) {
+		for _, token := range []string{
+			signTestJWT(t, "HS256", "", secret, validClaims()),
+			signTestJWT(t, "RS256", "rsa-key", rsaKey, validClaims()),
+			signTestJWT(t, "ES256", "ec-key", ecKey, validClaims()),
+			signTestJWT(t, "ES256", "", ecKey, validClaims()),
+		} {
+			claims, err := v.verify(token)
+			assert.Nil(t, err)
+			assert.Equal(t, "email-service", claims.Subject)
+			assert.True(t, claims.HasScope("email:send"))
+		}
+	})
+
+	t.Run("should reject token with invalid signature or algorithm", func(t *testing.T) {
+		_, err := v.verify(signTestJWT(t, "HS256", "", []byte("other-secret"), validClaims()))
+		assert.Equal(t, ErrTokenSignatureInvalid, err)
+
+		otherKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+		_, err = v.verify(signTestJWT(t, "RS256", "rsa-key", otherKey, validClaims()))
+		assert.Equal(t, ErrTokenSignatureInvalid, err)
+
+		_, err = v.verify(signTestJWT(t, "none", "", nil, validClaims()))
+		assert.Equal(t, ErrTokenAlgIsNotAllowed, err)
+
+		_, err = v.verify("not-a-token")
+		assert.Equal(t, ErrTokenIsMalformed, err)
+	})
+
+	t.Run("should reject token with invalid registered claims", func(t *testing.T) {
+		claims := validClaims()
+		claims["exp"] = time.Now().Add(-time.Hour).Unix()
+		_, err := v.verify(signTestJWT(t, "HS256", "", secret, claims))
+		assert.Equal(t, ErrTokenIsExpired, err)
+
+		claims = validClaims()
+		delete(claims, "exp")
+		_, err = v.verify(signTestJWT(t, "HS256", "", secret, claims))
+		assert.Equal(t, ErrTokenExpIsRequired, err)
+
+		claims = validClaims()
+		claims["nbf"] = time.Now().Add(time.Hour).Unix()
+		_, err = v.verify(signTestJWT(t, "HS256", "", secret, claims))
+		assert.Equal(t, ErrTokenIsNotValidYet, err)
+
+		claims = validClaims()
+		claims["iss"] = "someone-else"
+		_, err = v.verify(signTestJWT(t, "HS256", "", secret, claims))
+		assert.Equal(t, ErrTokenIssuerInvalid, err)
+
+		claims = validClaims()
+		claims["aud"] = "other"
+		_, err = v.verify(signTestJWT(t, "HS256", "", secret, claims))
+		assert.Equal(t, ErrTokenAudienceInvalid, err)
+	})
+
+	t.Run("should accept token without exp when it is allowed", func(t *testing.T) {
+		v, err := newJWTVerifier(&GRPCConfig{JWTSecret: string(secret), JWTAllowMissingExp: true})
+		assert.Nil(t, err)
+
+		claims := validClaims()
+		delete(claims, "exp")
+		_, err = v.verify(signTestJWT(t, "HS256", "", secret, claims))
+		assert.Nil(t, err)
+	})
+
+	t.Run("should require secret or JWKS", func(t *testing.T) {
+		_, err := newJWTVerifier(&GRPCConfig{})
+		assert.Equal(t, ErrJWTKeyIsRequired, err)
+	})
+}
+
+func Test_JWTAuthentication(t *testing.T) {
+	secret := []byte("shared-secret")
+	cfg := &GRPCConfig{AuthenticationType: AuthenticationTypeJWT, JWTSecret: string(secret)}
+
+	var claims *Claims
+	conn := dialTestServer(t, cfg,
+		WithMethodScopes(map[string][]string{"/postman.test.TestService/*": {"test:ping"}}),
+		WithUnaryInterceptors(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			claims, _ = ClaimsFromContext(ctx)
+			return handler(ctx, req)
+		}),
+	)
+
+	invoke := func(authorization string) error {
+		ctx := context.Background()
+		if authorization != "" {
+			ctx = metadata.AppendToOutgoingContext(ctx, BearerAuthorizationHeader, authorization)
+		}
+		return conn.Invoke(ctx, testUnaryMethod, wrapperspb.String("hello"), &wrapperspb.StringValue{})
+	}
+
+	t.Run("should reject missing or non bearer token", func(t *testing.T) {
+		assert.Equal(t, codes.Unauthenticated, status.Code(invoke("")))
+		assert.Equal(t, codes.Unauthenticated, status.Code(invoke("Basic abc")))
+	})
+
+	t.Run("should reject token without required scope", func(t *testing.T) {
+		token := signTestJWT(t, "HS256", "", secret, map[string]interface{}{
+			"sub": "email-service", "scope": "email:send", "exp": time.Now().Add(time.Hour).Unix(),
+		})
+		assert.Equal(t, codes.PermissionDenied, status.Code(invoke("Bearer "+token)))
+	})
+
+	t.Run("should expose claims to handler", func(t *testing.T) {
+		token := signTestJWT(t, "HS256", "", secret, map[string]interface{}{
+			"sub": "email-service", "scp": []string{"test:ping"}, "exp": time.Now().Add(time.Hour).Unix(),
+		})
+		assert.Nil(t, invoke("Bearer "+token))
+		assert.Equal(t, "email-service", claims.Subject)
+		assert.Equal(t, []string{"test:ping"}, claims.Scopes)
+	})
+}
@@ -0,0 +1,111 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+	"time"
+)
+
+var (
+	ErrServerIsAlreadyStarted = errors.New("Server is already started, the server can only run once")
+)
+
+// listen return the pre-opened listener when it is given, otherwise listen on the unix socket
+// when the path is set, or on the tcp address
+func listen(lis net.Listener, unixSocket, addr string) (net.Listener, error) {
+	if lis != nil {
+		return lis, nil
+	}
+
+	if unixSocket != "" {
+		if err := removeStaleSocket(unixSocket); err != nil {
+			return nil, err
+		}
+		return net.Listen("unix", unixSocket)
+	}
+
+	return net.Listen("tcp", addr)
+}
+
+// removeStaleSocket remove the socket left by the previous process, the path which is not a socket
+// or the socket which is still listened on is never removed
+func removeStaleSocket(path string) error {
+	info, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if info.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("Unix socket path %s exists and is not a socket", path)
+	}
+
+	// The socket is stale only when nothing is listening on it anymore
+	conn, err := net.DialTimeout("unix", path, time.Second)
+	if err == nil {
+		conn.Close()
+		return fmt.Errorf("Unix socket %s is already in use", path)
+	}
+	if !errors.Is(err, syscall.ECONNREFUSED) {
+		return err
+	}
+
+	return os.Remove(path)
+}
+
+// markReady record the bound address and release the caller waiting on Ready
+func (g *grpcServer) markReady(addr, metricAddr net.Addr) {
+	g.addrMu.Lock()
+	g.addr = addr
+	g.metricAddr = metricAddr
+	g.addrMu.Unlock()
+
+	g.readyOnce.Do(func() { close(g.ready) })
+}
+
+// markFailed record the error which stop the server before it is ready and release the caller waiting on Ready,
+// it is no-op once the server is ready
+func (g *grpcServer) markFailed(err error) {
+	g.readyOnce.Do(func() {
+		g.addrMu.Lock()
+		g.startErr = err
+		g.addrMu.Unlock()
+
+		close(g.ready)
+	})
+}
+
+// Ready is closed once the grpc and metrics server are bound to their address,
+// or when the server fail to start, StartErr return the error of the failed start
+func (g *grpcServer) Ready() <-chan struct{} {
+	return g.ready
+}
+
+// StartErr return the error which stop the server before it is ready, it is nil when the server is ready
+func (g *grpcServer) StartErr() error {
+	g.addrMu.RLock()
+	defer g.addrMu.RUnlock()
+
+	return g.startErr
+}
+
+// Addr return the address the grpc server is bound to, it is nil before the server is ready
+func (g *grpcServer) Addr() net.Addr {
+	g.addrMu.RLock()
+	defer g.addrMu.RUnlock()
+
+	return g.addr
+}
+
+// MetricAddr return the address the metrics server is bound to, it is nil before the server is ready
+func (g *grpcServer) MetricAddr() net.Addr {
+	g.addrMu.RLock()
+	defer g.addrMu.RUnlock()
+
+	return g.metricAddr
+}
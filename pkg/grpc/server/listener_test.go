@@ -0,0 +1,191 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// runUntilReady run the server in the background and stop it when the test finish
+func runUntilReady(t *testing.T, inst GrpcServer) {
+	ctx, cancel := context.WithCancel(context.Background())
+	errChan := make(chan error, 1)
+	go func() { errChan <- inst.RunContext(ctx) }()
+	t.Cleanup(func() {
+		cancel()
+		assert.Nil(t, <-errChan)
+	})
+
+	select {
+	case <-inst.Ready():
+		if err := inst.StartErr(); err != nil {
+			t.Fatalf("server fail to start: %v", err)
+		}
+	case err := <-errChan:
+		t.Fatalf("server exit before ready: %v", err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("server is not ready")
+	}
+}
+
+func checkHealth(t *testing.T, target string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	conn, err := grpc.DialContext(ctx, target, grpc.WithInsecure(), grpc.WithBlock())
+	assert.Nil(t, err)
+	defer conn.Close()
+
+	resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+	assert.Nil(t, err)
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, resp.Status)
+}
+
+func Test_Listener(t *testing.T) {
+	t.Run("should serve on the pre-opened listeners", func(t *testing.T) {
+		t.Parallel()
+		lis, err := net.Listen("tcp", "127.0.0.1:0")
+		assert.Nil(t, err)
+		metricLis, err := net.Listen("tcp", "127.0.0.1:0")
+		assert.Nil(t, err)
+
+		inst := NewGrpcServer(&GRPCConfig{MetricGracefulTimeout: 1}, nil, WithListener(lis), WithMetricListener(metricLis))
+		assert.Nil(t, inst.Addr())
+		runUntilReady(t, inst)
+
+		assert.Equal(t, lis.Addr(), inst.Addr())
+		assert.Equal(t, metricLis.Addr(), inst.MetricAddr())
+		checkHealth(t, inst.Addr().String())
+
+		resp, err := http.Get(fmt.Sprintf("http://%s/healthz", inst.MetricAddr()))
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		resp.Body.Close()
+	})
+
+	t.Run("should report the bound address of port 0", func(t *testing.T) {
+		t.Parallel()
+		inst := NewGrpcServer(&GRPCConfig{Host: "127.0.0.1", MetricHost: "127.0.0.1", MetricGracefulTimeout: 1}, nil)
+		runUntilReady(t, inst)
+
+		assert.NotEqual(t, 0, inst.Addr().(*net.TCPAddr).Port)
+		assert.NotEqual(t, 0, inst.MetricAddr().(*net.TCPAddr).Port)
+		checkHealth(t, inst.Addr().String())
+	})
+
+	t.Run("should serve on the unix socket", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		socket := filepath.Join(dir, "grpc.sock")
+		metricSocket := filepath.Join(dir, "metric.sock")
+
+		inst := NewGrpcServer(&GRPCConfig{UnixSocket: socket, MetricUnixSocket: metricSocket, MetricGracefulTimeout: 1}, nil)
+		runUntilReady(t, inst)
+
+		assert.Equal(t, "unix", inst.Addr().Network())
+		checkHealth(t, "unix://"+socket)
+
+		client := http.Client{Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, "unix", metricSocket)
+			},
+		}}
+		resp, err := client.Get("http://metrics/readyz")
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		resp.Body.Close()
+	})
+}
+
+func Test_listen(t *testing.T) {
+	t.Run("should remove the stale socket", func(t *testing.T) {
+		socket := filepath.Join(t.TempDir(), "grpc.sock")
+		stale, err := net.Listen("unix", socket)
+		assert.Nil(t, err)
+		stale.(*net.UnixListener).SetUnlinkOnClose(false)
+		stale.Close()
+
+		lis, err := listen(nil, socket, "")
+		assert.Nil(t, err)
+		lis.Close()
+	})
+
+	t.Run("should not take over the socket which is still listened on", func(t *testing.T) {
+		socket := filepath.Join(t.TempDir(), "grpc.sock")
+		live, err := net.Listen("unix", socket)
+		assert.Nil(t, err)
+		defer live.Close()
+
+		_, err = listen(nil, socket, "")
+		assert.NotNil(t, err)
+
+		conn, err := net.Dial("unix", socket)
+		assert.Nil(t, err)
+		conn.Close()
+	})
+
+	t.Run("should not remove the file which is not a socket", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "config.yaml")
+		assert.Nil(t, ioutil.WriteFile(path, []byte("keep"), 0600))
+
+		_, err := listen(nil, path, "")
+		assert.NotNil(t, err)
+
+		content, err := ioutil.ReadFile(path)
+		assert.Nil(t, err)
+		assert.Equal(t, "keep", string(content))
+	})
+
+	t.Run("should not remove the directory", func(t *testing.T) {
+		dir := t.TempDir()
+
+		_, err := listen(nil, dir, "")
+		assert.NotNil(t, err)
+
+		_, err = os.Stat(dir)
+		assert.Nil(t, err)
+	})
+}
+
+func Test_ReadyFailedStart(t *testing.T) {
+	t.Run("should release Ready when the server fail to start", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "config.yaml")
+		assert.Nil(t, ioutil.WriteFile(path, []byte("keep"), 0600))
+
+		inst := NewGrpcServer(&GRPCConfig{UnixSocket: path, MetricHost: "127.0.0.1", MetricGracefulTimeout: 1}, nil)
+		errChan := make(chan error, 1)
+		go func() { errChan <- inst.RunContext(context.Background()) }()
+
+		select {
+		case <-inst.Ready():
+		case <-time.After(5 * time.Second):
+			t.Fatal("Ready is not released")
+		}
+
+		err := <-errChan
+		assert.NotNil(t, err)
+		assert.Equal(t, err, inst.StartErr())
+		assert.Nil(t, inst.Addr())
+	})
+}
+
+func Test_RunContextTwice(t *testing.T) {
+	t.Run("should reject running the server again", func(t *testing.T) {
+		inst := NewGrpcServer(&GRPCConfig{Host: "127.0.0.1", MetricHost: "127.0.0.1", MetricGracefulTimeout: 1}, nil)
+		runUntilReady(t, inst)
+
+		assert.Equal(t, ErrServerIsAlreadyStarted, inst.RunContext(context.Background()))
+		assert.Equal(t, ErrServerIsAlreadyStarted, inst.Run())
+		assert.Nil(t, inst.StartErr())
+	})
+}
@@ -0,0 +1,60 @@
+package server
+
+import (
+	"errors"
+
+	grpcprometheus "github.com/grpc-ecosystem/go-grpc-prometheus"
+	"github.com/marprin/postman-lib/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/sirupsen/logrus"
+)
+
+// registerOrExisting register the collector, the collector already registered by another server
+// on the same registry is returned instead so both servers share it
+func registerOrExisting(reg prometheus.Registerer, c prometheus.Collector) prometheus.Collector {
+	if err := reg.Register(c); err != nil {
+		var are prometheus.AlreadyRegisteredError
+		if errors.As(err, &are) {
+			return are.ExistingCollector
+		}
+		logrus.WithError(err).Errorln("Failed to register the grpc server metrics")
+	}
+
+	return c
+}
+
+// initMetrics register the grpc, rate limit and deadline metrics together with the process and go runtime collectors,
+// the metrics already registered by another server on the same registry is reused
+func (g *grpcServer) initMetrics() {
+	if g.promRegistry == nil {
+		g.promRegistry = prometheus.NewRegistry()
+	}
+
+	grpcMetrics := grpcprometheus.NewServerMetrics()
+	if g.enableHistogram {
+		var opts []grpcprometheus.HistogramOption
+		if len(g.histogramBuckets) > 0 {
+			opts = append(opts, grpcprometheus.WithHistogramBuckets(g.histogramBuckets))
+		}
+		grpcMetrics.EnableHandlingTimeHistogram(opts...)
+	}
+	g.grpcMetrics = registerOrExisting(g.promRegistry, grpcMetrics).(*grpcprometheus.ServerMetrics)
+
+	if g.limiter != nil {
+		g.limiter.limited = registerOrExisting(g.promRegistry, g.limiter.limited).(*prometheus.CounterVec)
+	}
+
+	g.deadlineExceeded = registerOrExisting(g.promRegistry, prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "grpc_server_deadline_exceeded_total",
+		Help: "Total number of RPCs which arrive already expired or exceed the deadline on the server.",
+	}, []string{"grpc_method", "reason"})).(*prometheus.CounterVec)
+
+	err := metrics.Register(g.promRegistry,
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		collectors.NewGoCollector(),
+	)
+	if err != nil {
+		logrus.WithError(err).Errorln("Failed to register the process and go collectors")
+	}
+}
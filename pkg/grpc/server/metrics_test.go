@@ -0,0 +1,51 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+)
+
+func metricNames(t *testing.T, reg *prometheus.Registry) map[string]bool {
+	families, err := reg.Gather()
+	assert.Nil(t, err)
+
+	names := map[string]bool{}
+	for _, family := range families {
+		names[family.GetName()] = true
+	}
+	return names
+}
+
+func Test_Metrics(t *testing.T) {
+	t.Run("should register process and go collectors by default", func(t *testing.T) {
+		inst := NewGrpcServer(&GRPCConfig{}, nil)
+		names := metricNames(t, inst.MetricsRegistry())
+		assert.True(t, names["go_goroutines"])
+	})
+
+	t.Run("should share the external registry between servers", func(t *testing.T) {
+		reg := prometheus.NewRegistry()
+		first := NewGrpcServer(&GRPCConfig{}, nil, WithPrometheusRegistry(reg)).(*grpcServer)
+		second := NewGrpcServer(&GRPCConfig{}, nil, WithPrometheusRegistry(reg)).(*grpcServer)
+		assert.Equal(t, reg, first.MetricsRegistry())
+		assert.Equal(t, first.grpcMetrics, second.grpcMetrics)
+
+		counter := prometheus.NewCounter(prometheus.CounterOpts{Name: "service_custom_total", Help: "custom counter"})
+		reg.MustRegister(counter)
+		counter.Inc()
+		assert.True(t, metricNames(t, reg)["service_custom_total"])
+	})
+
+	t.Run("should record the handling time histogram when enabled", func(t *testing.T) {
+		inst := NewGrpcServer(&GRPCConfig{}, nil, WithHandlingTimeHistogram(0.1, 1)).(*grpcServer)
+		interceptor := inst.grpcMetrics.UnaryServerInterceptor()
+		_, err := interceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: testUnaryMethod},
+			func(ctx context.Context, req interface{}) (interface{}, error) { return nil, nil })
+		assert.Nil(t, err)
+		assert.True(t, metricNames(t, inst.MetricsRegistry())["grpc_server_handling_seconds"])
+	})
+}
@@ -0,0 +1,89 @@
+package server
+
+import (
+	"context"
+	"net"
+	"sync"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+type (
+	// MockServer is the in-memory grpc server for the test, it run the same interceptors
+	// and services as Run over the bufconn listener
+	MockServer struct {
+		listener     *bufconn.Listener
+		server       *grpc.Server
+		useTLS       bool
+		cancelHealth context.CancelFunc
+		serveErr     chan error
+		stopOnce     sync.Once
+		err          error
+	}
+)
+
+// mockBufferSize is the buffer size of the bufconn listener
+const mockBufferSize = 1024 * 1024
+
+// RunMock serve the grpc server over the bufconn listener, call Stop of the returned server to release it
+func (g *grpcServer) RunMock() (*MockServer, error) {
+	s, err := g.buildServer(g.cfg.UseTLS)
+	if err != nil {
+		return nil, err
+	}
+
+	healthCtx, cancelHealth := context.WithCancel(context.Background())
+	g.startHealthRegistry(healthCtx)
+
+	m := &MockServer{
+		listener:     bufconn.Listen(mockBufferSize),
+		server:       s,
+		useTLS:       g.cfg.UseTLS,
+		cancelHealth: cancelHealth,
+		serveErr:     make(chan error, 1),
+	}
+
+	go func() {
+		m.serveErr <- s.Serve(m.listener)
+	}()
+
+	return m, nil
+}
+
+// Listener return the bufconn listener of the server
+func (m *MockServer) Listener() *bufconn.Listener {
+	return m.listener
+}
+
+// Dialer return the dialer for grpc.WithContextDialer which connect to the server
+func (m *MockServer) Dialer() func(context.Context, string) (net.Conn, error) {
+	return func(context.Context, string) (net.Conn, error) {
+		return m.listener.Dial()
+	}
+}
+
+// Dial create the client connection to the server, the connection is insecure
+// unless the server use TLS, then the transport credentials must be given in the options
+func (m *MockServer) Dial(ctx context.Context, opts ...grpc.DialOption) (*grpc.ClientConn, error) {
+	dialOpts := []grpc.DialOption{grpc.WithContextDialer(m.Dialer())}
+	if !m.useTLS {
+		dialOpts = append(dialOpts, grpc.WithInsecure())
+	}
+
+	return grpc.DialContext(ctx, "bufnet", append(dialOpts, opts...)...)
+}
+
+// Stop stop the server and the health checks, it return the error of the serving server
+func (m *MockServer) Stop() error {
+	m.stopOnce.Do(func() {
+		m.cancelHealth()
+		m.server.Stop()
+		// Serve return ErrServerStopped when the server is stopped before it start serving
+		if err := <-m.serveErr; err != grpc.ErrServerStopped {
+			m.err = err
+		}
+	})
+
+	return m.err
+}
@@ -0,0 +1,125 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	rpcerrors "github.com/marprin/postman-lib/pkg/errors"
+	"github.com/marprin/postman-lib/pkg/healthcheck"
+	"github.com/marprin/postman-lib/proto/email"
+	"github.com/marprin/postman-lib/shared/constants"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func Test_RunMock(t *testing.T) {
+	cfg := &GRPCConfig{AuthenticationType: AuthenticationTypeClientSecretKey, ClientKey: "client", SecretKey: "secret"}
+	authCtx := metadata.AppendToOutgoingContext(context.Background(), AuthorizationHeader, EncodeClientSecretKey("client", "secret"))
+
+	listServices := func(ctx context.Context, conn *grpc.ClientConn) error {
+		stream, err := reflectionpb.NewServerReflectionClient(conn).ServerReflectionInfo(ctx)
+		if err != nil {
+			return err
+		}
+		err = stream.Send(&reflectionpb.ServerReflectionRequest{
+			MessageRequest: &reflectionpb.ServerReflectionRequest_ListServices{ListServices: "*"},
+		})
+		if err != nil {
+			return err
+		}
+		_, err = stream.Recv()
+		return err
+	}
+
+	t.Run("should serve health without authentication", func(t *testing.T) {
+		conn := dialTestServer(t, cfg)
+		resp, err := healthpb.NewHealthClient(conn).Check(context.Background(), &healthpb.HealthCheckRequest{})
+		assert.Nil(t, err)
+		assert.Equal(t, healthpb.HealthCheckResponse_SERVING, resp.Status)
+	})
+
+	t.Run("should protect reflection with the authentication", func(t *testing.T) {
+		conn := dialTestServer(t, cfg)
+		assert.Equal(t, codes.Unauthenticated, status.Code(listServices(context.Background(), conn)))
+		assert.Nil(t, listServices(authCtx, conn))
+	})
+
+	t.Run("should authenticate and recover panic as production", func(t *testing.T) {
+		conn := dialTestServer(t, cfg)
+
+		err := conn.Invoke(context.Background(), testUnaryMethod, wrapperspb.String("hello"), &wrapperspb.StringValue{})
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+		err = conn.Invoke(authCtx, testUnaryMethod, wrapperspb.String("panic"), &wrapperspb.StringValue{})
+		assert.Equal(t, codes.Internal, status.Code(err))
+
+		err = conn.Invoke(authCtx, testUnaryMethod, wrapperspb.String("hello"), &wrapperspb.StringValue{})
+		assert.Nil(t, err)
+	})
+
+	t.Run("should record the grpc metrics", func(t *testing.T) {
+		inst := NewGrpcServer(&GRPCConfig{}, func(s *grpc.Server) {
+			s.RegisterService(&testServiceDesc, struct{}{})
+		})
+		mock, err := inst.RunMock()
+		assert.Nil(t, err)
+		defer mock.Stop()
+
+		conn, err := mock.Dial(context.Background())
+		assert.Nil(t, err)
+		defer conn.Close()
+
+		err = conn.Invoke(context.Background(), testUnaryMethod, wrapperspb.String("hello"), &wrapperspb.StringValue{})
+		assert.Nil(t, err)
+		assert.True(t, metricNames(t, inst.MetricsRegistry())["grpc_server_handled_total"])
+	})
+
+	t.Run("should reflect the health registry", func(t *testing.T) {
+		registry := healthcheck.NewRegistry(0, 0)
+		registry.Register("redis", healthcheck.CheckerFunc(func(context.Context) error { return context.DeadlineExceeded }))
+		mock, err := NewGrpcServer(&GRPCConfig{}, nil, WithHealthRegistry(registry)).RunMock()
+		assert.Nil(t, err)
+		defer mock.Stop()
+
+		conn, err := mock.Dial(context.Background())
+		assert.Nil(t, err)
+		defer conn.Close()
+
+		registry.RunChecks(context.Background())
+		resp, err := healthpb.NewHealthClient(conn).Check(context.Background(), &healthpb.HealthCheckRequest{Service: "redis"})
+		assert.Nil(t, err)
+		assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, resp.Status)
+	})
+
+	t.Run("should stop the server", func(t *testing.T) {
+		mock, err := NewGrpcServer(&GRPCConfig{}, nil).RunMock()
+		assert.Nil(t, err)
+		assert.Nil(t, mock.Stop())
+		assert.Nil(t, mock.Stop())
+
+		_, err = mock.Listener().Dial()
+		assert.NotNil(t, err)
+	})
+}
+
+func Test_DomainError(t *testing.T) {
+	mock, err := NewGrpcServer(&GRPCConfig{}, func(s *grpc.Server) {
+		email.RegisterEmailServiceServer(s, testEmailServer{})
+	}).RunMock()
+	assert.Nil(t, err)
+	defer mock.Stop()
+
+	conn, err := mock.Dial(context.Background())
+	assert.Nil(t, err)
+	defer conn.Close()
+
+	_, err = email.NewEmailServiceClient(conn).CreateEmail(context.Background(), &email.Email{ToEmail: "invalid"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	assert.Equal(t, []rpcerrors.FieldViolation{{Field: "to_email", Description: constants.ErrToEmailIsNotValid.Error()}}, rpcerrors.FieldViolations(err))
+}
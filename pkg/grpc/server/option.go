@@ -0,0 +1,155 @@
+package server
+
+import (
+	"net"
+	"time"
+
+	"github.com/marprin/postman-lib/pkg/healthcheck"
+	"github.com/marprin/postman-lib/pkg/redact"
+	"github.com/opentracing/opentracing-go"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+)
+
+type (
+	// Option is the functional option to customize the grpc server instance
+	Option func(g *grpcServer)
+)
+
+// WithUnaryInterceptors append the unary interceptors after the built-in auth, logging and recovery interceptor
+func WithUnaryInterceptors(interceptors ...grpc.UnaryServerInterceptor) Option {
+	return func(g *grpcServer) {
+		g.unaryInterceptors = append(g.unaryInterceptors, interceptors...)
+	}
+}
+
+// WithStreamInterceptors append the stream interceptors after the built-in auth, logging and recovery interceptor
+func WithStreamInterceptors(interceptors ...grpc.StreamServerInterceptor) Option {
+	return func(g *grpcServer) {
+		g.streamInterceptors = append(g.streamInterceptors, interceptors...)
+	}
+}
+
+// WithServerOptions append raw grpc.ServerOption when creating the grpc.Server
+func WithServerOptions(opts ...grpc.ServerOption) Option {
+	return func(g *grpcServer) {
+		g.serverOptions = append(g.serverOptions, opts...)
+	}
+}
+
+// WithHealthServer use the given health server instead of creating a new one,
+// so the caller can set the serving status by themselve
+func WithHealthServer(hs *health.Server) Option {
+	return func(g *grpcServer) {
+		g.healthServer = hs
+	}
+}
+
+// WithoutHealthServer skip registering the grpc health service
+func WithoutHealthServer() Option {
+	return func(g *grpcServer) {
+		g.disableHealth = true
+	}
+}
+
+// WithTracer use the given tracer for the server span instead of the global tracer
+func WithTracer(tracer opentracing.Tracer) Option {
+	return func(g *grpcServer) {
+		g.tracer = tracer
+	}
+}
+
+// WithRedactor use the given redactor for the payload logging instead of the shared default redactor
+func WithRedactor(r *redact.Redactor) Option {
+	return func(g *grpcServer) {
+		g.redactor = r
+	}
+}
+
+// WithoutReflection skip registering the grpc reflection service
+func WithoutReflection() Option {
+	return func(g *grpcServer) {
+		g.disableReflection = true
+	}
+}
+
+// WithPolicies declare the authorization policy for each method, the key can be an exact full method
+// or a wildcard pattern like /postman.email.EmailService/*
+func WithPolicies(policies map[string]Policy) Option {
+	return func(g *grpcServer) {
+		if g.policies == nil {
+			g.policies = map[string]Policy{}
+		}
+		for method, p := range policies {
+			if _, ok := g.policies[method]; !ok {
+				g.policyPatterns = append(g.policyPatterns, method)
+			}
+			g.policies[method] = p
+		}
+	}
+}
+
+// WithMethodScopes declare the scopes required for each method, it is the shorthand of WithPolicies
+func WithMethodScopes(scopes map[string][]string) Option {
+	policies := make(map[string]Policy, len(scopes))
+	for method, s := range scopes {
+		policies[method] = Policy{Scopes: s}
+	}
+
+	return WithPolicies(policies)
+}
+
+// WithHealthRegistry run the dependency checkers of the registry while the server is running,
+// the result of each checker is the serving status of the service with the checker name
+func WithHealthRegistry(r *healthcheck.Registry) Option {
+	return func(g *grpcServer) {
+		g.healthRegistry = r
+	}
+}
+
+// WithPrometheusRegistry serve the metrics of the given registry, so the service and the library packages
+// like database, job and redis can register their collectors into the same registry
+func WithPrometheusRegistry(reg *prometheus.Registry) Option {
+	return func(g *grpcServer) {
+		g.promRegistry = reg
+	}
+}
+
+// WithHandlingTimeHistogram enable the grpc_server_handling_seconds latency histogram,
+// the default prometheus buckets is used when no bucket is given
+func WithHandlingTimeHistogram(buckets ...float64) Option {
+	return func(g *grpcServer) {
+		g.enableHistogram = true
+		g.histogramBuckets = buckets
+	}
+}
+
+// WithIdempotency store the response of the unary request with the idempotency-key metadata for the TTL,
+// the methods can be exact full methods or wildcard patterns, every unary method is idempotent when none is given.
+// The idempotency is not enabled when the TTL is not positive
+func WithIdempotency(store IdempotencyStore, ttl time.Duration, methods ...string) Option {
+	return func(g *grpcServer) {
+		if ttl <= 0 {
+			logrus.Errorf("Idempotency is not enabled, the TTL must be positive, got %s", ttl)
+			return
+		}
+		g.idempotency = newIdempotency(store, ttl, methods)
+	}
+}
+
+// WithListener serve the grpc server on the pre-opened listener instead of the configured address,
+// it is used for the socket activation or the test binding port 0
+func WithListener(lis net.Listener) Option {
+	return func(g *grpcServer) {
+		g.listener = lis
+	}
+}
+
+// WithMetricListener serve the metrics server on the pre-opened listener instead of the configured address
+func WithMetricListener(lis net.Listener) Option {
+	return func(g *grpcServer) {
+		g.metricListener = lis
+	}
+}
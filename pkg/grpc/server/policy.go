@@ -0,0 +1,114 @@
+package server
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/marprin/postman-lib/pkg/rpcmethod"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type (
+	// Policy is the authorization rule of a method. Public method skip the authentication,
+	// otherwise the caller must be authenticated, have at least one of the Roles and every Scopes
+	Policy struct {
+		Public bool
+		Roles  []string
+		Scopes []string
+	}
+)
+
+var (
+	// PublicPolicy allow the method to be called without authentication
+	PublicPolicy = Policy{Public: true}
+	// AuthenticatedPolicy allow every authenticated caller to call the method
+	AuthenticatedPolicy = Policy{}
+
+	ErrInsufficientRole  = "Caller does not have the required role"
+	ErrInsufficientScope = "Caller does not have the required scope"
+)
+
+// policyFor return the policy of the exact method, otherwise the policy of the longest matching pattern
+func (g *grpcServer) policyFor(method string) (Policy, bool) {
+	pattern, found := rpcmethod.LongestMatch(g.policyPatterns, method)
+	return g.policies[pattern], found
+}
+
+// authorize authenticate the caller and enforce the policy of the method,
+// a method without policy only require the authentication when it is configured
+func (g *grpcServer) authorize(ctx context.Context, method string) (context.Context, error) {
+	policy, found := g.policyFor(method)
+	if policy.Public {
+		return ctx, nil
+	}
+
+	if g.authenticate == nil {
+		if found {
+			return ctx, status.Error(codes.Unauthenticated, ErrAuthorizationTokenIsNotPresent)
+		}
+		return ctx, nil
+	}
+
+	ctx, err := g.authenticate(ctx, method)
+	if err != nil {
+		return ctx, err
+	}
+
+	claims, ok := ClaimsFromContext(ctx)
+	if !ok {
+		claims = &Claims{}
+	}
+
+	if len(policy.Roles) > 0 {
+		hasRole := false
+		for _, role := range policy.Roles {
+			if claims.HasRole(role) {
+				hasRole = true
+				break
+			}
+		}
+
+		if !hasRole {
+			return ctx, status.Error(codes.PermissionDenied, ErrInsufficientRole)
+		}
+	}
+
+	for _, scope := range policy.Scopes {
+		if !claims.HasScope(scope) {
+			return ctx, status.Error(codes.PermissionDenied, ErrInsufficientScope)
+		}
+	}
+
+	return ctx, nil
+}
+
+// warnMethodsWithoutPolicy log every registered method that does not have any policy,
+// the grpc internal services like health and reflection are skipped
+func (g *grpcServer) warnMethodsWithoutPolicy(s *grpc.Server) {
+	if len(g.policies) == 0 {
+		return
+	}
+
+	methods := []string{}
+	for svc, info := range s.GetServiceInfo() {
+		if strings.HasPrefix(svc, "grpc.") {
+			continue
+		}
+
+		for _, m := range info.Methods {
+			method := "/" + svc + "/" + m.Name
+			if _, found := g.policyFor(method); !found {
+				methods = append(methods, method)
+			}
+		}
+	}
+
+	sort.Strings(methods)
+	for _, method := range methods {
+		logrus.WithField("method", method).Warn("grpc method does not have any authorization policy")
+	}
+}
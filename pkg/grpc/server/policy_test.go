@@ -0,0 +1,85 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func Test_Policy(t *testing.T) {
+	cfg := &GRPCConfig{
+		AuthenticationType: AuthenticationTypeClientSecretKey,
+		Clients: []ClientCredential{
+			{ClientKey: "admin", SecretKey: "admin-secret", Roles: []string{"admin"}, Scopes: []string{"test:ping"}},
+			{ClientKey: "user", SecretKey: "user-secret", Roles: []string{"user"}},
+		},
+	}
+
+	invoke := func(conn *grpc.ClientConn, clientKey, secretKey string) error {
+		ctx := context.Background()
+		if clientKey != "" {
+			ctx = metadata.AppendToOutgoingContext(ctx, AuthorizationHeader, EncodeClientSecretKey(clientKey, secretKey))
+		}
+		return conn.Invoke(ctx, testUnaryMethod, wrapperspb.String("hello"), &wrapperspb.StringValue{})
+	}
+
+	t.Run("should allow public method without token", func(t *testing.T) {
+		conn := dialTestServer(t, cfg, WithPolicies(map[string]Policy{testUnaryMethod: PublicPolicy}))
+		assert.Nil(t, invoke(conn, "", ""))
+	})
+
+	t.Run("should require authentication for authenticated method", func(t *testing.T) {
+		conn := dialTestServer(t, cfg, WithPolicies(map[string]Policy{"/postman.test.TestService/*": AuthenticatedPolicy}))
+		assert.Equal(t, codes.Unauthenticated, status.Code(invoke(conn, "", "")))
+		assert.Nil(t, invoke(conn, "user", "user-secret"))
+	})
+
+	t.Run("should enforce roles and scopes", func(t *testing.T) {
+		conn := dialTestServer(t, cfg, WithPolicies(map[string]Policy{
+			"/postman.test.TestService/*": {Roles: []string{"admin", "operator"}, Scopes: []string{"test:ping"}},
+		}))
+		assert.Equal(t, codes.PermissionDenied, status.Code(invoke(conn, "user", "user-secret")))
+		assert.Nil(t, invoke(conn, "admin", "admin-secret"))
+	})
+
+	t.Run("should prefer exact method over wildcard", func(t *testing.T) {
+		conn := dialTestServer(t, cfg, WithPolicies(map[string]Policy{
+			"*":                           {Roles: []string{"admin"}},
+			"/postman.test.TestService/*": {Roles: []string{"admin"}},
+			testUnaryMethod:               PublicPolicy,
+		}))
+		assert.Nil(t, invoke(conn, "", ""))
+	})
+
+	t.Run("should reject protected method when authentication is not configured", func(t *testing.T) {
+		conn := dialTestServer(t, &GRPCConfig{}, WithPolicies(map[string]Policy{testUnaryMethod: AuthenticatedPolicy}))
+		assert.Equal(t, codes.Unauthenticated, status.Code(invoke(conn, "", "")))
+	})
+}
+
+func Test_warnMethodsWithoutPolicy(t *testing.T) {
+	hook := logtest.NewGlobal()
+	defer hook.Reset()
+
+	s := grpc.NewServer()
+	s.RegisterService(&testServiceDesc, struct{}{})
+
+	inst := NewGrpcServer(&GRPCConfig{}, nil, WithPolicies(map[string]Policy{testUnaryMethod: PublicPolicy})).(*grpcServer)
+	inst.warnMethodsWithoutPolicy(s)
+
+	warned := []string{}
+	for _, entry := range hook.AllEntries() {
+		if entry.Level == logrus.WarnLevel {
+			warned = append(warned, entry.Data["method"].(string))
+		}
+	}
+	assert.Equal(t, []string{testStreamMethod}, warned)
+}
@@ -0,0 +1,215 @@
+package server
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/marprin/postman-lib/pkg/rpcmethod"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+type (
+	// MethodLimit is the rate and concurrency limit of the method, the method can be an exact full method
+	// or a wildcard pattern like /postman.email.EmailService/*. RequestsPerSecond and Burst limit each client
+	// of the method, MaxInFlight limit the concurrent requests of the method across every client
+	MethodLimit struct {
+		Method            string
+		RequestsPerSecond float64
+		Burst             int
+		MaxInFlight       int
+	}
+
+	tokenBucket struct {
+		tokens float64
+		last   time.Time
+		// refill is the time the empty bucket take to be full again
+		refill time.Duration
+	}
+
+	limiter struct {
+		limits    map[string]MethodLimit
+		patterns  []string
+		clients   map[string]bool
+		now       func() time.Time
+		mu        sync.Mutex
+		buckets   map[string]*tokenBucket
+		lastSweep time.Time
+		inFlight  map[string]int
+		limited   *prometheus.CounterVec
+	}
+)
+
+const (
+	// RetryAfterMetadataKey is the trailer with the seconds the client should wait before retrying the limited request
+	RetryAfterMetadataKey = "retry-after"
+
+	limitReasonRate        = "rate"
+	limitReasonConcurrency = "concurrency"
+
+	// limitOtherClient is the client label of the client which is not configured, like the JWT subject
+	limitOtherClient = "other"
+
+	// limiterSweepInterval is the minimum interval between removing the idle buckets
+	limiterSweepInterval = time.Minute
+)
+
+var (
+	ErrRateLimitExceeded       = "Rate limit is exceeded"
+	ErrTooManyInFlightRequests = "Too many in-flight requests"
+)
+
+// newLimiter create the limiter of the method limits, the clients is the configured client keys
+// which are labelled on the metric as they are, the other clients are labelled as "other"
+func newLimiter(limits []MethodLimit, clients []string) *limiter {
+	l := &limiter{
+		limits:   make(map[string]MethodLimit, len(limits)),
+		clients:  make(map[string]bool, len(clients)),
+		now:      time.Now,
+		buckets:  map[string]*tokenBucket{},
+		inFlight: map[string]int{},
+		limited: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "grpc_server_limited_total",
+			Help: "Total number of RPCs rejected by the rate or concurrency limit on the server.",
+		}, []string{"grpc_method", "client", "reason"}),
+	}
+
+	for _, limit := range limits {
+		if _, ok := l.limits[limit.Method]; !ok {
+			l.patterns = append(l.patterns, limit.Method)
+		}
+		l.limits[limit.Method] = limit
+	}
+
+	for _, client := range clients {
+		l.clients[client] = true
+	}
+
+	return l
+}
+
+// clientLabel return the client label of the metric, the cardinality is bounded by the configured clients
+func (l *limiter) clientLabel(client string) string {
+	if l.clients[client] {
+		return client
+	}
+
+	return limitOtherClient
+}
+
+// sweep remove the buckets which have been idle long enough to be full again, they are the same as the new bucket.
+// The caller must hold the lock
+func (l *limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < limiterSweepInterval {
+		return
+	}
+	l.lastSweep = now
+
+	for key, bucket := range l.buckets {
+		if now.Sub(bucket.last) >= bucket.refill {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// limitFor return the limit of the exact method, otherwise the limit of the longest matching pattern
+func (l *limiter) limitFor(method string) (MethodLimit, bool) {
+	pattern, found := rpcmethod.LongestMatch(l.patterns, method)
+	return l.limits[pattern], found
+}
+
+// takeToken refill the bucket of the client and method then take a token,
+// it return the duration until the next token when the bucket is empty
+func (l *limiter) takeToken(key string, limit MethodLimit) (bool, time.Duration) {
+	burst := float64(limit.Burst)
+	if burst < 1 {
+		burst = 1
+	}
+
+	now := l.now()
+	bucket, ok := l.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{
+			tokens: burst,
+			last:   now,
+			refill: time.Duration(burst / limit.RequestsPerSecond * float64(time.Second)),
+		}
+		l.buckets[key] = bucket
+	}
+
+	bucket.tokens = math.Min(burst, bucket.tokens+now.Sub(bucket.last).Seconds()*limit.RequestsPerSecond)
+	bucket.last = now
+
+	if bucket.tokens < 1 {
+		return false, time.Duration((1 - bucket.tokens) / limit.RequestsPerSecond * float64(time.Second))
+	}
+
+	bucket.tokens--
+	return true, 0
+}
+
+// acquire check the rate and concurrency limit of the client and method,
+// the release must be called when the request is done
+func (l *limiter) acquire(client, method string) (release func(), retryAfter time.Duration, err error) {
+	release = func() {}
+
+	limit, found := l.limitFor(method)
+	if !found {
+		return release, 0, nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.sweep(l.now())
+
+	if limit.MaxInFlight > 0 && l.inFlight[method] >= limit.MaxInFlight {
+		l.limited.WithLabelValues(method, l.clientLabel(client), limitReasonConcurrency).Inc()
+		return release, time.Second, status.Error(codes.ResourceExhausted, ErrTooManyInFlightRequests)
+	}
+
+	if limit.RequestsPerSecond > 0 {
+		if ok, wait := l.takeToken(client+"|"+method, limit); !ok {
+			l.limited.WithLabelValues(method, l.clientLabel(client), limitReasonRate).Inc()
+			return release, wait, status.Error(codes.ResourceExhausted, ErrRateLimitExceeded)
+		}
+	}
+
+	if limit.MaxInFlight <= 0 {
+		return release, 0, nil
+	}
+
+	l.inFlight[method]++
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.mu.Lock()
+			l.inFlight[method]--
+			l.mu.Unlock()
+		})
+	}, 0, nil
+}
+
+// retryAfterTrailer return the retry-after trailer in whole seconds, rounded up
+func retryAfterTrailer(d time.Duration) metadata.MD {
+	seconds := int64(math.Ceil(d.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+
+	return metadata.Pairs(RetryAfterMetadataKey, strconv.FormatInt(seconds, 10))
+}
+
+// limit apply the limiter to the authenticated client of the context, it is no-op without the configured limits
+func (g *grpcServer) limit(ctx context.Context, method string) (func(), time.Duration, error) {
+	if g.limiter == nil {
+		return func() {}, 0, nil
+	}
+
+	client, _ := ClientIDFromContext(ctx)
+	return g.limiter.acquire(client, method)
+}
@@ -0,0 +1,119 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func Test_limiter(t *testing.T) {
+	now := time.Unix(0, 0)
+	l := newLimiter([]MethodLimit{
+		{Method: "/postman.email.EmailService/*", RequestsPerSecond: 2, Burst: 2},
+		{Method: "/postman.sms.SMSService/SendSMS", MaxInFlight: 1},
+	}, []string{"email-client"})
+	l.now = func() time.Time { return now }
+
+	t.Run("should limit each client with its own bucket", func(t *testing.T) {
+		for i := 0; i < 2; i++ {
+			_, _, err := l.acquire("email-client", "/postman.email.EmailService/CreateEmail")
+			assert.Nil(t, err)
+		}
+
+		_, retryAfter, err := l.acquire("email-client", "/postman.email.EmailService/CreateEmail")
+		assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+		assert.Equal(t, 500*time.Millisecond, retryAfter)
+
+		_, _, err = l.acquire("other-client", "/postman.email.EmailService/CreateEmail")
+		assert.Nil(t, err)
+
+		now = now.Add(500 * time.Millisecond)
+		_, _, err = l.acquire("email-client", "/postman.email.EmailService/CreateEmail")
+		assert.Nil(t, err)
+	})
+
+	t.Run("should limit the in-flight requests of the method", func(t *testing.T) {
+		release, _, err := l.acquire("sms-client", "/postman.sms.SMSService/SendSMS")
+		assert.Nil(t, err)
+
+		_, _, err = l.acquire("other-client", "/postman.sms.SMSService/SendSMS")
+		assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+
+		release()
+		release()
+		_, _, err = l.acquire("other-client", "/postman.sms.SMSService/SendSMS")
+		assert.Nil(t, err)
+	})
+
+	t.Run("should label the client which is not configured as other", func(t *testing.T) {
+		assert.Equal(t, "email-client", l.clientLabel("email-client"))
+		assert.Equal(t, limitOtherClient, l.clientLabel("jwt-subject"))
+		assert.Equal(t, float64(1), testutil.ToFloat64(l.limited.WithLabelValues("/postman.email.EmailService/CreateEmail", "email-client", limitReasonRate)))
+		assert.Equal(t, float64(1), testutil.ToFloat64(l.limited.WithLabelValues("/postman.sms.SMSService/SendSMS", limitOtherClient, limitReasonConcurrency)))
+	})
+
+	t.Run("should remove the bucket which is full again after idle", func(t *testing.T) {
+		_, _, err := l.acquire("idle-client", "/postman.email.EmailService/CreateEmail")
+		assert.Nil(t, err)
+		assert.Contains(t, l.buckets, "idle-client|/postman.email.EmailService/CreateEmail")
+
+		now = now.Add(limiterSweepInterval)
+		_, _, err = l.acquire("other-client", "/postman.email.EmailService/CreateEmail")
+		assert.Nil(t, err)
+		assert.NotContains(t, l.buckets, "idle-client|/postman.email.EmailService/CreateEmail")
+	})
+
+	t.Run("should not limit the method without limit", func(t *testing.T) {
+		for i := 0; i < 10; i++ {
+			_, _, err := l.acquire("client", testUnaryMethod)
+			assert.Nil(t, err)
+		}
+	})
+}
+
+func Test_RateLimitInterceptor(t *testing.T) {
+	t.Run("should reject with retry-after trailer and count the rejection", func(t *testing.T) {
+		inst := NewGrpcServer(&GRPCConfig{MethodLimits: []MethodLimit{{Method: testUnaryMethod, RequestsPerSecond: 0.5, Burst: 1}}}, func(s *grpc.Server) {
+			s.RegisterService(&testServiceDesc, struct{}{})
+		})
+		mock, err := inst.RunMock()
+		assert.Nil(t, err)
+		defer mock.Stop()
+
+		conn, err := mock.Dial(context.Background())
+		assert.Nil(t, err)
+		defer conn.Close()
+
+		err = conn.Invoke(context.Background(), testUnaryMethod, wrapperspb.String("hello"), &wrapperspb.StringValue{})
+		assert.Nil(t, err)
+
+		var trailer metadata.MD
+		err = conn.Invoke(context.Background(), testUnaryMethod, wrapperspb.String("hello"), &wrapperspb.StringValue{}, grpc.Trailer(&trailer))
+		assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+		assert.Equal(t, []string{"2"}, trailer.Get(RetryAfterMetadataKey))
+		assert.True(t, metricNames(t, inst.MetricsRegistry())["grpc_server_limited_total"])
+	})
+
+	t.Run("should reject the stream over the in-flight limit", func(t *testing.T) {
+		conn := dialTestServer(t, &GRPCConfig{MethodLimits: []MethodLimit{{Method: testStreamMethod, MaxInFlight: 1}}})
+
+		first, err := conn.NewStream(context.Background(), &testServiceDesc.Streams[0], testStreamMethod)
+		assert.Nil(t, err)
+		assert.Nil(t, first.SendMsg(wrapperspb.String("hello")))
+		assert.Nil(t, first.RecvMsg(&wrapperspb.StringValue{}))
+
+		second, err := conn.NewStream(context.Background(), &testServiceDesc.Streams[0], testStreamMethod)
+		assert.Nil(t, err)
+		err = second.RecvMsg(&wrapperspb.StringValue{})
+		assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+		assert.Equal(t, []string{"1"}, second.Trailer().Get(RetryAfterMetadataKey))
+	})
+}
@@ -2,31 +2,30 @@ package server
 
 import (
 	"context"
-	"encoding/base64"
 	"errors"
 	"fmt"
+	"math"
 	"net"
 	"net/http"
 	"os"
 	"os/signal"
-	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
-	"github.com/google/uuid"
 	grpcmiddleware "github.com/grpc-ecosystem/go-grpc-middleware"
 	grpcprometheus "github.com/grpc-ecosystem/go-grpc-prometheus"
-	"github.com/marprin/postman-lib/pkg/panic"
+	"github.com/marprin/postman-lib/pkg/healthcheck"
+	"github.com/marprin/postman-lib/pkg/redact"
+	"github.com/marprin/postman-lib/pkg/tracing"
+	"github.com/opentracing/opentracing-go"
 	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/sirupsen/logrus"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/health"
 	healthpb "google.golang.org/grpc/health/grpc_health_v1"
-	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/reflection"
-	"google.golang.org/grpc/status"
-	"google.golang.org/grpc/test/bufconn"
 )
 
 type (
@@ -34,25 +33,86 @@ type (
 	AuthenticationType string
 	registerSvcFunc    func(s *grpc.Server)
 
-	// GRPCConfig is the object config for initialize the grpc instance
+	// GRPCConfig is the object config for initialize the grpc instance,
+	// the timeout and period fields are in seconds
 	GRPCConfig struct {
 		Host                  string
 		Port                  uint
 		MetricHost            string
 		MetricPort            uint
+		UnixSocket            string
+		MetricUnixSocket      string
+		GatewayHost           string
+		GatewayPort           uint
 		MetricGracefulTimeout time.Duration
+		ShutdownDrainPeriod   uint
+		ShutdownTimeout       uint
 		UseTLS                bool
 		ServerCertFile        string
 		ServerKeyFile         string
+		ClientCAFile          string
 		ClientKey             string
 		SecretKey             string
+		Clients               []ClientCredential
+		JWTSecret             string
+		JWKSFile              string
+		JWTIssuer             string
+		JWTAudience           string
+		JWTAllowMissingExp    bool
 		AuthenticationType    AuthenticationType
+		MethodLimits          []MethodLimit
+		DefaultTimeout        uint
+		MaxTimeout            uint
+		MethodTimeouts        []MethodTimeout
+
+		// transport config, the unset message size and keepalive use the defaults
+		MaxRecvMsgSize               int
+		MaxSendMsgSize               int
+		MaxConcurrentStreams         uint32
+		KeepaliveTime                uint
+		KeepaliveTimeout             uint
+		KeepaliveMinTime             uint
+		KeepalivePermitWithoutStream bool
+		MaxConnectionIdle            uint
+		MaxConnectionAge             uint
+		MaxConnectionAgeGrace        uint
 	}
 
 	grpcServer struct {
-		cfg                        *GRPCConfig
-		registerSvcFunc            registerSvcFunc
-		unaryInterceptorMiddleware grpc.UnaryServerInterceptor
+		cfg                *GRPCConfig
+		registerSvcFunc    registerSvcFunc
+		authenticate       authenticateFunc
+		jwtVerifier        *jwtVerifier
+		policies           map[string]Policy
+		policyPatterns     []string
+		limiter            *limiter
+		idempotency        *idempotency
+		methodTimeouts     map[string]time.Duration
+		timeoutPatterns    []string
+		unaryInterceptors  []grpc.UnaryServerInterceptor
+		streamInterceptors []grpc.StreamServerInterceptor
+		serverOptions      []grpc.ServerOption
+		healthServer       *health.Server
+		tracer             opentracing.Tracer
+		redactor           *redact.Redactor
+		healthRegistry     *healthcheck.Registry
+		listener           net.Listener
+		metricListener     net.Listener
+		addrMu             sync.RWMutex
+		addr               net.Addr
+		metricAddr         net.Addr
+		ready              chan struct{}
+		readyOnce          sync.Once
+		startErr           error
+		promRegistry       *prometheus.Registry
+		grpcMetrics        *grpcprometheus.ServerMetrics
+		deadlineExceeded   *prometheus.CounterVec
+		histogramBuckets   []float64
+		enableHistogram    bool
+		draining           int32
+		started            int32
+		disableHealth      bool
+		disableReflection  bool
 	}
 )
 
@@ -70,78 +130,207 @@ var (
 	ErrFailedExtractMetadata          = "Failed when extract metadata"
 	ErrAuthorizationTokenIsNotPresent = "Authorization token is not present"
 	ErrAuthorizationTokenIsNotValid   = "Authorization token is not valid"
+	ErrMethodIsNotAllowed             = "Method is not allowed for the client"
 )
 
+// maxSeconds is the longest seconds count which fit into time.Duration
+const maxSeconds = uint(math.MaxInt64 / int64(time.Second))
+
+// seconds convert the seconds count of the config into time.Duration, the count which overflow
+// time.Duration is capped to the longest duration
+func seconds(n uint) time.Duration {
+	if n > maxSeconds {
+		n = maxSeconds
+	}
+
+	return time.Duration(n) * time.Second
+}
+
 // NewGrpcServer Initialize grpc instance
-func NewGrpcServer(cfg *GRPCConfig, fn registerSvcFunc) GrpcServer {
+func NewGrpcServer(cfg *GRPCConfig, fn registerSvcFunc, opts ...Option) GrpcServer {
 	inst := &grpcServer{
 		cfg:             cfg,
 		registerSvcFunc: fn,
+		ready:           make(chan struct{}),
+	}
+
+	// Set the authentication of the middleware
+	switch cfg.AuthenticationType {
+	case AuthenticationTypeClientSecretKey:
+		inst.authenticate = inst.authenticateClientSecretKey
+	case AuthenticationTypeJWT:
+		inst.authenticate = inst.authenticateJWT
 	}
 
-	// Set the middleware
-	if cfg.AuthenticationType == AuthenticationTypeClientSecretKey {
-		inst.unaryInterceptorMiddleware = inst.clientSecretKeyUnaryInterceptorHandler
-	} else {
-		inst.unaryInterceptorMiddleware = inst.defaultUnaryInterceptorHandler
+	for _, opt := range opts {
+		opt(inst)
 	}
 
+	if len(cfg.MethodLimits) > 0 {
+		clients := []string{}
+		for _, c := range inst.clientCredentials() {
+			clients = append(clients, c.ClientKey)
+		}
+		inst.limiter = newLimiter(cfg.MethodLimits, clients)
+	}
+
+	if inst.healthServer == nil {
+		inst.healthServer = health.NewServer()
+	}
+
+	inst.initMethodTimeouts()
+
+	inst.initMetrics()
+
 	return inst
 }
 
-// CustomizeUnaryInterceptorMiddleware is the setter function so the user can customize the middleware by themselve
-func (g *grpcServer) CustomizeUnaryInterceptorMiddleware(fnc grpc.UnaryServerInterceptor) {
-	g.unaryInterceptorMiddleware = fnc
-}
+// newServer create the grpc.Server with the built-in interceptors followed by the user interceptors,
+// the given leading interceptors are placed before everything else
+func (g *grpcServer) newServer(unary []grpc.UnaryServerInterceptor, stream []grpc.StreamServerInterceptor, useTLS bool) (*grpc.Server, error) {
+	// Apply the transport defaults on the copy, so the config of the caller is untouched
+	transport := *g.cfg
+	transport.applyTransportDefaults()
+	if err := transport.ValidateTransport(); err != nil {
+		return nil, err
+	}
 
-func (g *grpcServer) Run() error {
-	promRegistry := prometheus.NewRegistry()
-	grpcMetrics := grpcprometheus.NewServerMetrics()
+	// Load the signing keys for the JWT authentication
+	if g.cfg.AuthenticationType == AuthenticationTypeJWT {
+		verifier, err := newJWTVerifier(g.cfg)
+		if err != nil {
+			return nil, err
+		}
+		g.jwtVerifier = verifier
+	}
 
-	// Register the prometheus standard metrics
-	promRegistry.MustRegister(grpcMetrics)
+	unary = append(unary, tracing.UnaryServerInterceptor(g.tracer), g.unaryInterceptorHandler)
+	unary = append(unary, g.unaryInterceptors...)
 
-	httpAddr := fmt.Sprintf("%s:%d", g.cfg.Host, g.cfg.Port)
-	metricAddr := fmt.Sprintf("%s:%d", g.cfg.MetricHost, g.cfg.MetricPort)
+	stream = append(stream, tracing.StreamServerInterceptor(g.tracer), g.streamInterceptorHandler)
+	stream = append(stream, g.streamInterceptors...)
 
-	httpListener, err := net.Listen("tcp", httpAddr)
-	if err != nil {
-		return err
+	opts := []grpc.ServerOption{
+		grpc.UnaryInterceptor(grpcmiddleware.ChainUnaryServer(unary...)),
+		grpc.StreamInterceptor(grpcmiddleware.ChainStreamServer(stream...)),
+	}
+	opts = append(opts, transport.transportOptions()...)
+	opts = append(opts, g.serverOptions...)
+
+	// Serve with TLS, the client certificate is verified when the client CA is set,
+	// the in-process connection of the HTTP gateway skip the handshake
+	if useTLS {
+		creds, err := newTransportCredentials(g.cfg)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, grpc.Creds(inProcessCredentials{creds}))
 	}
 
-	grpcServer := grpc.NewServer(
-		grpc.UnaryInterceptor(grpcmiddleware.ChainUnaryServer(
-			grpcMetrics.UnaryServerInterceptor(),
-			g.unaryInterceptorMiddleware,
-		)),
-	)
+	return grpc.NewServer(opts...), nil
+}
 
+// registerServices register the proto, health and reflection service into the grpc server
+func (g *grpcServer) registerServices(s *grpc.Server) {
 	// Register the proto service
 	if g.registerSvcFunc != nil {
-		g.registerSvcFunc(grpcServer)
+		g.registerSvcFunc(s)
 	}
 
 	// Register the health check service
-	healthpb.RegisterHealthServer(grpcServer, health.NewServer())
+	if !g.disableHealth {
+		healthpb.RegisterHealthServer(s, g.healthServer)
+	}
 
 	// Register the reflection of grpc server
-	reflection.Register(grpcServer)
+	if !g.disableReflection {
+		reflection.Register(s)
+	}
+
+	g.warnMethodsWithoutPolicy(s)
+}
+
+// buildServer create the grpc server with the metrics interceptors and register every service,
+// Run and RunMock share it so every server run the same stack as production
+func (g *grpcServer) buildServer(useTLS bool) (*grpc.Server, error) {
+	s, err := g.newServer(
+		[]grpc.UnaryServerInterceptor{g.grpcMetrics.UnaryServerInterceptor()},
+		[]grpc.StreamServerInterceptor{g.grpcMetrics.StreamServerInterceptor()},
+		useTLS,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	g.registerServices(s)
 
 	// Initialize the metrics
-	grpcMetrics.InitializeMetrics(grpcServer)
+	g.grpcMetrics.InitializeMetrics(s)
+
+	return s, nil
+}
+
+func (g *grpcServer) Run() error {
+	return g.RunContext(context.Background())
+}
+
+// RunContext run the grpc and metrics server until the context is done or the terminating signal is received,
+// the server can only run once
+func (g *grpcServer) RunContext(ctx context.Context) (err error) {
+	if !atomic.CompareAndSwapInt32(&g.started, 0, 1) {
+		return ErrServerIsAlreadyStarted
+	}
+	// release the caller waiting on Ready when the server fail to start
+	defer func() { g.markFailed(err) }()
+
+	httpAddr := fmt.Sprintf("%s:%d", g.cfg.Host, g.cfg.Port)
+	metricAddr := fmt.Sprintf("%s:%d", g.cfg.MetricHost, g.cfg.MetricPort)
+
+	grpcServer, err := g.buildServer(g.cfg.UseTLS)
+	if err != nil {
+		return err
+	}
+
+	httpListener, err := listen(g.listener, g.cfg.UnixSocket, httpAddr)
+	if err != nil {
+		return err
+	}
+
+	metricListener, err := listen(g.metricListener, g.cfg.MetricUnixSocket, metricAddr)
+	if err != nil {
+		httpListener.Close()
+		return err
+	}
+	httpAddr, metricAddr = httpListener.Addr().String(), metricListener.Addr().String()
+	g.markReady(httpListener.Addr(), metricListener.Addr())
 
 	httpMetricServer := &http.Server{
-		Handler: promhttp.HandlerFor(promRegistry, promhttp.HandlerOpts{}),
-		Addr:    metricAddr,
+		Handler: g.metricsHandler(g.promRegistry),
 	}
 
 	logrus.Infoln("Starting Metrics Server")
 	go func() {
-		if err := httpMetricServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		if err := httpMetricServer.Serve(metricListener); err != nil && err != http.ErrServerClosed {
 			logrus.Errorf("Failed to start HTTP metric server for GRPC on %s", metricAddr)
 		}
 	}()
 
+	// Serve the HTTP/JSON gateway when the gateway port is set
+	var gw *gatewayServer
+	if g.cfg.GatewayPort > 0 {
+		gw, err = g.startGateway(grpcServer)
+		if err != nil {
+			httpListener.Close()
+			g.shutdownMetricServer(httpMetricServer)
+			return err
+		}
+	}
+
+	// Run the dependency checks until the server is shutting down
+	healthCtx, cancelHealth := context.WithCancel(ctx)
+	defer cancelHealth()
+	g.startHealthRegistry(healthCtx)
+
 	errChan := make(chan error, 1)
 	logrus.Infoln("Starting GRPC Server")
 	go func() {
@@ -153,138 +342,30 @@ func (g *grpcServer) Run() error {
 	logrus.Infof("GRPC server started on %s and Metrics server started on %s", httpAddr, metricAddr)
 
 	termChan := make(chan os.Signal, 1)
-	signal.Notify(termChan, os.Interrupt, os.Kill)
+	signal.Notify(termChan, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(termChan)
 
 	select {
 	case sig := <-termChan:
 		logrus.Infof("Receive terminating signal, prepare for shutdown service, %s", sig)
-
-		logrus.Infoln("Trying to terminate GRPC Server")
-		grpcServer.GracefulStop()
-		logrus.Infoln("Successfully graceful stop GRPC server")
-
-		logrus.Infoln("Trying to terminate metrics server")
-		ctx, cancel := context.WithTimeout(context.Background(), g.cfg.MetricGracefulTimeout*time.Second)
-		defer cancel()
-
-		if err := httpMetricServer.Shutdown(ctx); err != nil {
-			logrus.Infof("Force shutdown metrics server, error: %s", err)
-		}
-		logrus.Infoln("Successfully terminate Metric server")
+	case <-ctx.Done():
+		logrus.Infof("Context is done, prepare for shutdown service, %s", ctx.Err())
 	case err := <-errChan:
+		gw.stop()
+		g.shutdownMetricServer(httpMetricServer)
 		pErr := fmt.Errorf("Exiting with error: %+v", err)
 		return errors.New(pErr.Error())
 	}
 
-	return nil
-}
-
-func (g *grpcServer) RunMock() (*bufconn.Listener, error) {
-	lis := bufconn.Listen(1024 * 1024)
-
-	s := grpc.NewServer(
-		grpc.UnaryInterceptor(grpcmiddleware.ChainUnaryServer(
-			grpc.UnaryServerInterceptor(g.unaryInterceptorMiddleware),
-		)),
-	)
-
-	// registering services
-	if g.registerSvcFunc != nil {
-		g.registerSvcFunc(s)
-	}
-	var err error
-
-	go func() {
-		err = s.Serve(lis)
-	}()
-
-	return lis, err
-}
-
-func (g *grpcServer) clientSecretKeyUnaryInterceptorHandler(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
-	timeStart := time.Now()
-	reqID := uuid.New().String()
-	method := info.FullMethod
-	// handle any panic ocured on the server
-	defer panic.HandlePanic(func(r interface{}) {
-		panic.ToPanicError(r, info.FullMethod)
-		err = status.Errorf(codes.Internal, "%s", r)
-	})
-
-	// skip loging health check probe
-	if strings.HasPrefix(method, "/grpc.health") {
-		return handler(ctx, req)
-	}
-
-	// Handle the client key validation
-	metadata, ok := metadata.FromIncomingContext(ctx)
-	if !ok {
-		return nil, status.Error(codes.Internal, ErrFailedExtractMetadata)
-	}
-
-	if len(metadata[AuthorizationHeader]) == 0 {
-		return nil, status.Error(codes.Unauthenticated, ErrAuthorizationTokenIsNotPresent)
-	}
-
-	token := metadata[AuthorizationHeader][0]
-	decToken, err := base64.StdEncoding.DecodeString(token)
-	if err != nil {
-		return nil, status.Error(codes.Unauthenticated, ErrAuthorizationTokenIsNotValid)
-	}
-
-	clientKey := string(decToken)
-	if g.cfg.ClientKey != clientKey[:len(clientKey)-1] {
-		return nil, status.Error(codes.Unauthenticated, ErrAuthorizationTokenIsNotValid)
-	}
-
-	LogUnaryRequest(reqID, method, req)
-	resp, err = handler(ctx, req)
-	LogUnaryResponse(reqID, method, timeStart, resp, err)
-
-	return resp, err
-}
+	cancelHealth()
+	g.shutdown(grpcServer, gw)
+	g.shutdownMetricServer(httpMetricServer)
 
-func (g *grpcServer) defaultUnaryInterceptorHandler(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
-	timeStart := time.Now()
-	reqID := uuid.New().String()
-	method := info.FullMethod
-	// handle any panic ocured on the server
-	defer panic.HandlePanic(func(r interface{}) {
-		panic.ToPanicError(r, info.FullMethod)
-		err = status.Errorf(codes.Internal, "%s", r)
-	})
-
-	// skip loging health check probe
-	if strings.HasPrefix(method, "/grpc.health") {
-		return handler(ctx, req)
-	}
-
-	LogUnaryRequest(reqID, method, req)
-	resp, err = handler(ctx, req)
-	LogUnaryResponse(reqID, method, timeStart, resp, err)
-
-	return resp, err
-}
-
-func LogUnaryRequest(reqID, method string, req interface{}) {
-	logrus.WithFields(logrus.Fields{
-		"req_id": reqID,
-		"method": method,
-		"req":    req,
-	}).Info("incoming rpc unary request")
+	return nil
 }
 
-func LogUnaryResponse(reqID, method string, timeStart time.Time, resp interface{}, err error) {
-	fields := logrus.Fields{
-		"req_id": reqID,
-		"method": method,
-		"took":   time.Since(timeStart),
-	}
-
-	if err != nil {
-		logrus.WithFields(fields).WithError(err).Error("rpc unary request failed")
-	} else {
-		fields["resp"] = resp
-		logrus.WithFields(fields).Info("rpc unary request succeeded")
-	}
+// MetricsRegistry return the prometheus registry served by the metrics server,
+// the service can register its own collectors into it
+func (g *grpcServer) MetricsRegistry() *prometheus.Registry {
+	return g.promRegistry
 }
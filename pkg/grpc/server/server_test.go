@@ -0,0 +1,226 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/marprin/postman-lib/pkg/redact"
+	"github.com/marprin/postman-lib/pkg/requestid"
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+const (
+	testUnaryMethod  = "/postman.test.TestService/Ping"
+	testStreamMethod = "/postman.test.TestService/Echo"
+)
+
+var testServiceDesc = grpc.ServiceDesc{
+	ServiceName: "postman.test.TestService",
+	HandlerType: (*interface{})(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Ping",
+			Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+				in := &wrapperspb.StringValue{}
+				if err := dec(in); err != nil {
+					return nil, err
+				}
+				handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+					in := req.(*wrapperspb.StringValue)
+					if in.Value == "panic" {
+						panic("unary panic")
+					}
+					return in, nil
+				}
+				if interceptor == nil {
+					return handler(ctx, in)
+				}
+				return interceptor(ctx, in, &grpc.UnaryServerInfo{Server: srv, FullMethod: testUnaryMethod}, handler)
+			},
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Echo",
+			ServerStreams: true,
+			ClientStreams: true,
+			Handler: func(srv interface{}, stream grpc.ServerStream) error {
+				for {
+					in := &wrapperspb.StringValue{}
+					if err := stream.RecvMsg(in); err != nil {
+						return nil
+					}
+					if in.Value == "panic" {
+						panic("stream panic")
+					}
+					if err := stream.SendMsg(in); err != nil {
+						return err
+					}
+				}
+			},
+		},
+	},
+}
+
+func dialTestServer(t *testing.T, cfg *GRPCConfig, opts ...Option) *grpc.ClientConn {
+	inst := NewGrpcServer(cfg, func(s *grpc.Server) {
+		s.RegisterService(&testServiceDesc, struct{}{})
+	}, opts...)
+	mock, err := inst.RunMock()
+	assert.Nil(t, err)
+	t.Cleanup(func() { assert.Nil(t, mock.Stop()) })
+
+	conn, err := mock.Dial(context.Background())
+	assert.Nil(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	return conn
+}
+
+func dialTestStream(t *testing.T, cfg *GRPCConfig, ctx context.Context) grpc.ClientStream {
+	conn := dialTestServer(t, cfg)
+
+	stream, err := conn.NewStream(ctx, &testServiceDesc.Streams[0], testStreamMethod)
+	assert.Nil(t, err)
+	return stream
+}
+
+func Test_NewGrpcServerOptions(t *testing.T) {
+	t.Run("should run user interceptor after the built-in interceptor", func(t *testing.T) {
+		called := false
+		conn := dialTestServer(t,
+			&GRPCConfig{AuthenticationType: AuthenticationTypeClientSecretKey, ClientKey: "client"},
+			WithUnaryInterceptors(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+				called = true
+				return handler(ctx, req)
+			}),
+		)
+
+		err := conn.Invoke(context.Background(), testUnaryMethod, wrapperspb.String("hello"), &wrapperspb.StringValue{})
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+		assert.False(t, called)
+	})
+
+	t.Run("should keep panic recovery around user interceptor", func(t *testing.T) {
+		conn := dialTestServer(t, &GRPCConfig{},
+			WithUnaryInterceptors(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+				panic("interceptor panic")
+			}),
+		)
+
+		err := conn.Invoke(context.Background(), testUnaryMethod, wrapperspb.String("hello"), &wrapperspb.StringValue{})
+		assert.Equal(t, codes.Internal, status.Code(err))
+	})
+
+	t.Run("should chain multiple user interceptors in order", func(t *testing.T) {
+		order := []string{}
+		record := func(name string) grpc.UnaryServerInterceptor {
+			return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+				order = append(order, name)
+				return handler(ctx, req)
+			}
+		}
+		conn := dialTestServer(t, &GRPCConfig{}, WithUnaryInterceptors(record("first")), WithUnaryInterceptors(record("second")))
+
+		out := &wrapperspb.StringValue{}
+		err := conn.Invoke(context.Background(), testUnaryMethod, wrapperspb.String("hello"), out)
+		assert.Nil(t, err)
+		assert.Equal(t, "hello", out.Value)
+		assert.Equal(t, []string{"first", "second"}, order)
+	})
+}
+
+func Test_RequestID(t *testing.T) {
+	var handlerReqID string
+	conn := dialTestServer(t, &GRPCConfig{},
+		WithUnaryInterceptors(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			handlerReqID, _ = RequestIDFromContext(ctx)
+			return handler(ctx, req)
+		}),
+	)
+
+	t.Run("should honour incoming request id", func(t *testing.T) {
+		var header metadata.MD
+		ctx := metadata.AppendToOutgoingContext(context.Background(), requestid.MetadataKey, "req-from-caller")
+		err := conn.Invoke(ctx, testUnaryMethod, wrapperspb.String("hello"), &wrapperspb.StringValue{}, grpc.Header(&header))
+		assert.Nil(t, err)
+		assert.Equal(t, "req-from-caller", handlerReqID)
+		assert.Equal(t, []string{"req-from-caller"}, header.Get(requestid.MetadataKey))
+	})
+
+	t.Run("should generate request id when absent", func(t *testing.T) {
+		var header metadata.MD
+		err := conn.Invoke(context.Background(), testUnaryMethod, wrapperspb.String("hello"), &wrapperspb.StringValue{}, grpc.Header(&header))
+		assert.Nil(t, err)
+		assert.NotEmpty(t, handlerReqID)
+		assert.Equal(t, []string{handlerReqID}, header.Get(requestid.MetadataKey))
+	})
+
+	t.Run("should echo request id on stream", func(t *testing.T) {
+		ctx := metadata.AppendToOutgoingContext(context.Background(), requestid.MetadataKey, "stream-req")
+		stream := dialTestStream(t, &GRPCConfig{}, ctx)
+		assert.Nil(t, stream.SendMsg(wrapperspb.String("hello")))
+		header, err := stream.Header()
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"stream-req"}, header.Get(requestid.MetadataKey))
+	})
+}
+
+func Test_ServerTracing(t *testing.T) {
+	tracer := mocktracer.New()
+	conn := dialTestServer(t, &GRPCConfig{}, WithTracer(tracer))
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), requestid.MetadataKey, "traced-req")
+	err := conn.Invoke(ctx, testUnaryMethod, wrapperspb.String("hello"), &wrapperspb.StringValue{})
+	assert.Nil(t, err)
+
+	spans := tracer.FinishedSpans()
+	assert.Len(t, spans, 1)
+	assert.Equal(t, testUnaryMethod, spans[0].OperationName)
+	assert.Equal(t, "traced-req", spans[0].Tag("request_id"))
+}
+
+func Test_PayloadRedaction(t *testing.T) {
+	hook := logtest.NewGlobal()
+	defer hook.Reset()
+
+	requestLog := func() *logrus.Entry {
+		for _, entry := range hook.AllEntries() {
+			if entry.Message == "incoming rpc unary request" {
+				return entry
+			}
+		}
+		return nil
+	}
+
+	t.Run("should log redacted payload", func(t *testing.T) {
+		hook.Reset()
+		conn := dialTestServer(t, &GRPCConfig{}, WithRedactor(redact.New(redact.Config{
+			Fields: map[string]redact.Action{"value": redact.ActionMask},
+		})))
+
+		err := conn.Invoke(context.Background(), testUnaryMethod, wrapperspb.String("secret"), &wrapperspb.StringValue{})
+		assert.Nil(t, err)
+		assert.Equal(t, map[string]interface{}{"value": redact.MaskedValue}, requestLog().Data["req"])
+	})
+
+	t.Run("should omit payload of the turned down method", func(t *testing.T) {
+		hook.Reset()
+		conn := dialTestServer(t, &GRPCConfig{}, WithRedactor(redact.New(redact.Config{
+			Methods: map[string]redact.MethodRule{"/postman.test.TestService/*": {OmitPayload: true}},
+		})))
+
+		err := conn.Invoke(context.Background(), testUnaryMethod, wrapperspb.String("secret"), &wrapperspb.StringValue{})
+		assert.Nil(t, err)
+		_, found := requestLog().Data["req"]
+		assert.False(t, found)
+	})
+}
@@ -0,0 +1,67 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+)
+
+// defaultShutdownTimeout is the graceful stop deadline when ShutdownTimeout is not set
+const defaultShutdownTimeout = 10
+
+// shutdown flip the health service to NOT_SERVING, wait the drain period so the load balancer
+// stop sending new request, stop the HTTP gateway while the grpc server still serve its in-flight request,
+// then graceful stop the grpc server and force stop it after the deadline
+func (g *grpcServer) shutdown(s *grpc.Server, gw *gatewayServer) {
+	logrus.Infoln("Set health status to NOT_SERVING")
+	atomic.StoreInt32(&g.draining, 1)
+	g.healthServer.Shutdown()
+
+	if g.cfg.ShutdownDrainPeriod > 0 {
+		logrus.Infof("Waiting %d seconds for the connections to drain", g.cfg.ShutdownDrainPeriod)
+		time.Sleep(seconds(g.cfg.ShutdownDrainPeriod))
+	}
+
+	gw.stop()
+
+	timeout := g.cfg.ShutdownTimeout
+	if timeout == 0 {
+		timeout = defaultShutdownTimeout
+	}
+
+	logrus.Infoln("Trying to terminate GRPC Server")
+	stopped := make(chan struct{})
+	go func() {
+		s.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		logrus.Infoln("Successfully graceful stop GRPC server")
+	case <-time.After(seconds(timeout)):
+		logrus.Warnln("Graceful stop GRPC server timed out, force stop the server")
+		s.Stop()
+	}
+}
+
+func (g *grpcServer) shutdownMetricServer(httpMetricServer *http.Server) {
+	// MetricGracefulTimeout keep its time.Duration type but it is the seconds count like the other timeouts
+	timeout := g.cfg.MetricGracefulTimeout
+	if timeout < 0 {
+		timeout = 0
+	}
+
+	logrus.Infoln("Trying to terminate metrics server")
+	ctx, cancel := context.WithTimeout(context.Background(), seconds(uint(timeout)))
+	defer cancel()
+
+	if err := httpMetricServer.Shutdown(ctx); err != nil {
+		logrus.Infof("Force shutdown metrics server, error: %s", err)
+	}
+	logrus.Infoln("Successfully terminate Metric server")
+}
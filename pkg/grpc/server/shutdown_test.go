@@ -0,0 +1,112 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func Test_RunContext(t *testing.T) {
+	hs := health.NewServer()
+	inst := NewGrpcServer(&GRPCConfig{Host: "127.0.0.1", MetricHost: "127.0.0.1", MetricGracefulTimeout: 1}, nil, WithHealthServer(hs))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errChan := make(chan error, 1)
+	go func() { errChan <- inst.RunContext(ctx) }()
+
+	time.Sleep(100 * time.Millisecond)
+	cancel()
+
+	select {
+	case err := <-errChan:
+		assert.Nil(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("RunContext does not return after the context is cancelled")
+	}
+
+	resp, err := hs.Check(context.Background(), &healthpb.HealthCheckRequest{})
+	assert.Nil(t, err)
+	assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, resp.Status)
+}
+
+// serveBlockedStream serve the grpc server with the open stream, so the graceful stop never finish
+func serveBlockedStream(t *testing.T) (*grpc.Server, grpc.ClientStream) {
+	lis := bufconn.Listen(1024 * 1024)
+	s := grpc.NewServer()
+	s.RegisterService(&testServiceDesc, struct{}{})
+	go func() { _ = s.Serve(lis) }()
+
+	conn, err := grpc.DialContext(context.Background(), "bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return lis.Dial() }),
+		grpc.WithInsecure(),
+	)
+	assert.Nil(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	stream, err := conn.NewStream(context.Background(), &testServiceDesc.Streams[0], testStreamMethod)
+	assert.Nil(t, err)
+	assert.Nil(t, stream.SendMsg(wrapperspb.String("hello")))
+	assert.Nil(t, stream.RecvMsg(&wrapperspb.StringValue{}))
+
+	return s, stream
+}
+
+func Test_shutdown(t *testing.T) {
+	t.Run("should force stop when graceful stop exceed the timeout", func(t *testing.T) {
+		inst := NewGrpcServer(&GRPCConfig{ShutdownTimeout: 1}, nil).(*grpcServer)
+		s, stream := serveBlockedStream(t)
+
+		start := time.Now()
+		inst.shutdown(s, nil)
+		assert.True(t, time.Since(start) < 3*time.Second)
+		assert.NotNil(t, stream.RecvMsg(&wrapperspb.StringValue{}))
+	})
+
+	t.Run("should stop the gateway before the graceful stop", func(t *testing.T) {
+		inst := NewGrpcServer(&GRPCConfig{ShutdownTimeout: 1}, nil).(*grpcServer)
+		s, _ := serveBlockedStream(t)
+
+		lis, err := net.Listen("tcp", "127.0.0.1:0")
+		assert.Nil(t, err)
+		gwConn, err := grpc.Dial("passthrough:///gateway", grpc.WithInsecure())
+		assert.Nil(t, err)
+		gw := &gatewayServer{
+			httpServer: &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {})},
+			conn:       gwConn,
+			timeout:    1,
+		}
+		go func() { _ = gw.httpServer.Serve(lis) }()
+		url := fmt.Sprintf("http://%s/", lis.Addr())
+
+		done := make(chan struct{})
+		go func() {
+			inst.shutdown(s, gw)
+			close(done)
+		}()
+
+		assert.Eventually(t, func() bool {
+			resp, err := http.Get(url)
+			if err == nil {
+				resp.Body.Close()
+			}
+			return err != nil
+		}, 500*time.Millisecond, 5*time.Millisecond)
+
+		select {
+		case <-done:
+			t.Fatal("grpc server is stopped before the gateway")
+		default:
+		}
+		<-done
+	})
+}
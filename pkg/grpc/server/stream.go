@@ -0,0 +1,124 @@
+package server
+
+import (
+	"context"
+	"time"
+
+	rpcerrors "github.com/marprin/postman-lib/pkg/errors"
+	"github.com/marprin/postman-lib/pkg/panic"
+	"github.com/marprin/postman-lib/pkg/redact"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type (
+	// loggingServerStream wrap the grpc.ServerStream so every message can be logged
+	loggingServerStream struct {
+		grpc.ServerStream
+		ctx      context.Context
+		method   string
+		redactor *redact.Redactor
+		sampled  bool
+	}
+)
+
+func (s *loggingServerStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *loggingServerStream) RecvMsg(m interface{}) error {
+	err := s.ServerStream.RecvMsg(m)
+	if err == nil && s.sampled {
+		LogStreamMessage(s.ctx, s.method, "recv", s.redactor.Redact(m))
+	}
+	return err
+}
+
+func (s *loggingServerStream) SendMsg(m interface{}) error {
+	err := s.ServerStream.SendMsg(m)
+	if err == nil && s.sampled {
+		LogStreamMessage(s.ctx, s.method, "send", s.redactor.Redact(m))
+	}
+	return err
+}
+
+func (g *grpcServer) streamInterceptorHandler(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+	timeStart := time.Now()
+	method := info.FullMethod
+	// handle any panic ocured on the server
+	defer panic.HandlePanic(func(r interface{}) {
+		panic.ToPanicError(r, info.FullMethod)
+		err = status.Errorf(codes.Internal, "%s", r)
+	})
+
+	// skip loging health check probe
+	if isHealthCheck(method) {
+		return handler(srv, ss)
+	}
+
+	ctx := withRequestID(ss.Context())
+	_ = ss.SetHeader(requestIDHeader(ctx))
+
+	ctx, cancel, err := g.withDeadline(ctx, method)
+	if err != nil {
+		return err
+	}
+	defer cancel()
+	defer g.countDeadlineExceeded(ctx, method)
+
+	ctx, err = g.authorize(ctx, method)
+	if err != nil {
+		return err
+	}
+
+	release, retryAfter, err := g.limit(ctx, method)
+	if err != nil {
+		ss.SetTrailer(retryAfterTrailer(retryAfter))
+		return err
+	}
+	defer release()
+
+	LogStreamRequest(ctx, method, info)
+	redactor := g.payloadRedactor()
+	err = handler(srv, &loggingServerStream{
+		ServerStream: ss,
+		ctx:          ctx,
+		method:       method,
+		redactor:     redactor,
+		sampled:      redactor.Sampled(method),
+	})
+	err = rpcerrors.Convert(err)
+	LogStreamResponse(ctx, method, timeStart, err)
+
+	return err
+}
+
+func LogStreamRequest(ctx context.Context, method string, info *grpc.StreamServerInfo) {
+	logrus.WithFields(contextLogFields(ctx)).WithFields(logrus.Fields{
+		"method":        method,
+		"client_stream": info.IsClientStream,
+		"server_stream": info.IsServerStream,
+	}).Info("incoming rpc stream request")
+}
+
+func LogStreamMessage(ctx context.Context, method, direction string, msg interface{}) {
+	logrus.WithFields(contextLogFields(ctx)).WithFields(logrus.Fields{
+		"method":    method,
+		"direction": direction,
+		"msg":       msg,
+	}).Info("rpc stream message")
+}
+
+func LogStreamResponse(ctx context.Context, method string, timeStart time.Time, err error) {
+	fields := contextLogFields(ctx)
+	fields["method"] = method
+	fields["took"] = time.Since(timeStart)
+
+	if err != nil {
+		logrus.WithFields(fields).WithError(err).Error("rpc stream request failed")
+	} else {
+		logrus.WithFields(fields).Info("rpc stream request succeeded")
+	}
+}
@@ -0,0 +1,50 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func Test_StreamInterceptor(t *testing.T) {
+	t.Run("should echo message without authentication", func(t *testing.T) {
+		stream := dialTestStream(t, &GRPCConfig{}, context.Background())
+
+		assert.Nil(t, stream.SendMsg(wrapperspb.String("hello")))
+		out := &wrapperspb.StringValue{}
+		assert.Nil(t, stream.RecvMsg(out))
+		assert.Equal(t, "hello", out.Value)
+	})
+
+	t.Run("should recover panic as internal error", func(t *testing.T) {
+		stream := dialTestStream(t, &GRPCConfig{}, context.Background())
+
+		assert.Nil(t, stream.SendMsg(wrapperspb.String("panic")))
+		err := stream.RecvMsg(&wrapperspb.StringValue{})
+		assert.Equal(t, codes.Internal, status.Code(err))
+	})
+
+	t.Run("should reject stream without authorization token", func(t *testing.T) {
+		cfg := &GRPCConfig{AuthenticationType: AuthenticationTypeClientSecretKey, ClientKey: "client"}
+		stream := dialTestStream(t, cfg, context.Background())
+
+		err := stream.RecvMsg(&wrapperspb.StringValue{})
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+
+	t.Run("should accept stream with valid authorization token", func(t *testing.T) {
+		cfg := &GRPCConfig{AuthenticationType: AuthenticationTypeClientSecretKey, ClientKey: "client", SecretKey: "secret"}
+		ctx := metadata.AppendToOutgoingContext(context.Background(), AuthorizationHeader, EncodeClientSecretKey("client", "secret"))
+		stream := dialTestStream(t, cfg, ctx)
+
+		assert.Nil(t, stream.SendMsg(wrapperspb.String("hello")))
+		out := &wrapperspb.StringValue{}
+		assert.Nil(t, stream.RecvMsg(out))
+		assert.Equal(t, "hello", out.Value)
+	})
+}
@@ -0,0 +1,213 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+type (
+	// certReloader keep the server certificate and client CA in memory and reload them when the files change on disk
+	certReloader struct {
+		certFile     string
+		keyFile      string
+		clientCAFile string
+
+		mu        sync.RWMutex
+		cert      *tls.Certificate
+		clientCAs *x509.CertPool
+		modTime   time.Time
+		lastCheck time.Time
+	}
+)
+
+// certReloadCheckInterval is the minimum interval between checking the certificate files modification time
+const certReloadCheckInterval = 5 * time.Second
+
+var (
+	ErrServerCertificateIsRequired = errors.New("Server certificate and key file is required when TLS is enabled")
+	ErrClientCAIsNotValid          = errors.New("Client CA file does not contain any valid certificate")
+)
+
+func newCertReloader(certFile, keyFile, clientCAFile string) (*certReloader, error) {
+	if certFile == "" || keyFile == "" {
+		return nil, ErrServerCertificateIsRequired
+	}
+
+	r := &certReloader{
+		certFile:     certFile,
+		keyFile:      keyFile,
+		clientCAFile: clientCAFile,
+	}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (r *certReloader) reload() error {
+	modTime, err := r.latestModTime()
+	if err != nil {
+		return err
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+
+	var clientCAs *x509.CertPool
+	if r.clientCAFile != "" {
+		caPEM, err := ioutil.ReadFile(r.clientCAFile)
+		if err != nil {
+			return err
+		}
+
+		clientCAs = x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(caPEM) {
+			return ErrClientCAIsNotValid
+		}
+	}
+
+	r.mu.Lock()
+	r.cert = &cert
+	r.clientCAs = clientCAs
+	r.modTime = modTime
+	r.lastCheck = time.Now()
+	r.mu.Unlock()
+
+	return nil
+}
+
+// latestModTime return the most recent modification time across the certificate files
+func (r *certReloader) latestModTime() (time.Time, error) {
+	var latest time.Time
+	for _, fname := range []string{r.certFile, r.keyFile, r.clientCAFile} {
+		if fname == "" {
+			continue
+		}
+
+		info, err := os.Stat(fname)
+		if err != nil {
+			return latest, err
+		}
+
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+
+	return latest, nil
+}
+
+// maybeReload reload the certificate when any of the files has been changed since the last load,
+// the old certificate is kept when the new one fail to load
+func (r *certReloader) maybeReload() {
+	r.mu.Lock()
+	if time.Since(r.lastCheck) < certReloadCheckInterval {
+		r.mu.Unlock()
+		return
+	}
+	r.lastCheck = time.Now()
+	loadedAt := r.modTime
+	r.mu.Unlock()
+
+	modTime, err := r.latestModTime()
+	if err != nil {
+		logrus.WithError(err).Error("Failed to check TLS certificate files")
+		return
+	}
+
+	if !modTime.After(loadedAt) {
+		return
+	}
+
+	if err := r.reload(); err != nil {
+		logrus.WithError(err).Error("Failed to reload TLS certificate, keep using the previous one")
+		return
+	}
+	logrus.Infoln("Successfully reload TLS certificate")
+}
+
+// getConfigForClient build the tls config of the grpc handshake from the latest loaded certificate
+func (r *certReloader) getConfigForClient(*tls.ClientHelloInfo) (*tls.Config, error) {
+	return r.configForClient("h2")
+}
+
+// getHTTPConfigForClient build the tls config of the HTTP handshake from the latest loaded certificate
+func (r *certReloader) getHTTPConfigForClient(*tls.ClientHelloInfo) (*tls.Config, error) {
+	return r.configForClient("h2", "http/1.1")
+}
+
+// configForClient build the tls config for each handshake from the latest loaded certificate,
+// the client certificate is required when the client CA is set
+func (r *certReloader) configForClient(nextProtos ...string) (*tls.Config, error) {
+	r.maybeReload()
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	cfg := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{*r.cert},
+		NextProtos:   nextProtos,
+	}
+	if r.clientCAs != nil {
+		cfg.ClientCAs = r.clientCAs
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return cfg, nil
+}
+
+// newTransportCredentials create the grpc transport credentials from the config
+func newTransportCredentials(cfg *GRPCConfig) (credentials.TransportCredentials, error) {
+	r, err := newCertReloader(cfg.ServerCertFile, cfg.ServerKeyFile, cfg.ClientCAFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return credentials.NewTLS(&tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		GetConfigForClient: r.getConfigForClient,
+	}), nil
+}
+
+// newHTTPTLSConfig create the tls config of the HTTP listener with the same certificate and client CA as the grpc server
+func newHTTPTLSConfig(cfg *GRPCConfig) (*tls.Config, error) {
+	r, err := newCertReloader(cfg.ServerCertFile, cfg.ServerKeyFile, cfg.ClientCAFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		GetConfigForClient: r.getHTTPConfigForClient,
+	}, nil
+}
+
+// PeerCertificateSubject return the subject of the verified client certificate of the current request
+func PeerCertificateSubject(ctx context.Context) (pkix.Name, bool) {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.AuthInfo == nil {
+		return pkix.Name{}, false
+	}
+
+	tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(tlsInfo.State.VerifiedChains) == 0 || len(tlsInfo.State.VerifiedChains[0]) == 0 {
+		return pkix.Name{}, false
+	}
+
+	return tlsInfo.State.VerifiedChains[0][0].Subject, true
+}
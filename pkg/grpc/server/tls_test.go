@@ -0,0 +1,188 @@
+package server
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newTestCert(t *testing.T, cn string, parent *testCert, isCA bool) *testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	assert.Nil(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: cn},
+		DNSNames:              []string{"localhost"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  isCA,
+	}
+
+	signerCert, signerKey := tmpl, key
+	if parent != nil {
+		signerCert, signerKey = parent.cert, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signerCert, &key.PublicKey, signerKey)
+	assert.Nil(t, err)
+
+	cert, err := x509.ParseCertificate(der)
+	assert.Nil(t, err)
+
+	return &testCert{cert: cert, key: key}
+}
+
+func (c *testCert) write(t *testing.T, dir, name string) (string, string) {
+	certFile := filepath.Join(dir, name+".crt")
+	keyFile := filepath.Join(dir, name+".key")
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.cert.Raw})
+	assert.Nil(t, ioutil.WriteFile(certFile, certPEM, 0600))
+
+	keyDER, err := x509.MarshalECPrivateKey(c.key)
+	assert.Nil(t, err)
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	assert.Nil(t, ioutil.WriteFile(keyFile, keyPEM, 0600))
+
+	return certFile, keyFile
+}
+
+func (c *testCert) tlsCertificate() tls.Certificate {
+	return tls.Certificate{Certificate: [][]byte{c.cert.Raw}, PrivateKey: c.key}
+}
+
+func Test_TLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCert(t, "postman-ca", nil, true)
+	caFile, _ := ca.write(t, dir, "ca")
+	serverCertFile, serverKeyFile := newTestCert(t, "localhost", ca, false).write(t, dir, "server")
+	client := newTestCert(t, "email-service", ca, false)
+
+	caPool := x509.NewCertPool()
+	caPool.AddCert(ca.cert)
+
+	dialTLS := func(t *testing.T, cfg *GRPCConfig, clientTLS *tls.Config, opts ...Option) error {
+		inst := NewGrpcServer(cfg, func(s *grpc.Server) {
+			s.RegisterService(&testServiceDesc, struct{}{})
+		}, opts...)
+		mock, err := inst.RunMock()
+		assert.Nil(t, err)
+		defer mock.Stop()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		conn, err := mock.Dial(ctx, grpc.WithTransportCredentials(credentials.NewTLS(clientTLS)))
+		assert.Nil(t, err)
+		defer conn.Close()
+
+		return conn.Invoke(ctx, testUnaryMethod, wrapperspb.String("hello"), &wrapperspb.StringValue{}, grpc.WaitForReady(false))
+	}
+
+	t.Run("should serve with server TLS", func(t *testing.T) {
+		cfg := &GRPCConfig{UseTLS: true, ServerCertFile: serverCertFile, ServerKeyFile: serverKeyFile}
+		err := dialTLS(t, cfg, &tls.Config{ServerName: "localhost", RootCAs: caPool})
+		assert.Nil(t, err)
+	})
+
+	t.Run("should expose peer certificate subject with mutual TLS", func(t *testing.T) {
+		var subject pkix.Name
+		var found bool
+		cfg := &GRPCConfig{UseTLS: true, ServerCertFile: serverCertFile, ServerKeyFile: serverKeyFile, ClientCAFile: caFile}
+		err := dialTLS(t, cfg,
+			&tls.Config{ServerName: "localhost", RootCAs: caPool, Certificates: []tls.Certificate{client.tlsCertificate()}},
+			WithUnaryInterceptors(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+				subject, found = PeerCertificateSubject(ctx)
+				return handler(ctx, req)
+			}),
+		)
+		assert.Nil(t, err)
+		assert.True(t, found)
+		assert.Equal(t, "email-service", subject.CommonName)
+	})
+
+	t.Run("should reject client without certificate with mutual TLS", func(t *testing.T) {
+		cfg := &GRPCConfig{UseTLS: true, ServerCertFile: serverCertFile, ServerKeyFile: serverKeyFile, ClientCAFile: caFile}
+		err := dialTLS(t, cfg, &tls.Config{ServerName: "localhost", RootCAs: caPool})
+		assert.NotNil(t, err)
+	})
+
+	t.Run("should return error when certificate is missing", func(t *testing.T) {
+		_, err := NewGrpcServer(&GRPCConfig{UseTLS: true}, nil).RunMock()
+		assert.Equal(t, ErrServerCertificateIsRequired, err)
+
+		_, err = NewGrpcServer(&GRPCConfig{UseTLS: true, ServerCertFile: filepath.Join(dir, "missing.crt"), ServerKeyFile: serverKeyFile}, nil).RunMock()
+		assert.NotNil(t, err)
+	})
+}
+
+func Test_CertReloader(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCert(t, "postman-ca", nil, true)
+	certFile, keyFile := newTestCert(t, "first", ca, false).write(t, dir, "server")
+
+	r, err := newCertReloader(certFile, keyFile, "")
+	assert.Nil(t, err)
+
+	cfg, err := r.getConfigForClient(nil)
+	assert.Nil(t, err)
+	first := cfg.Certificates[0].Certificate[0]
+
+	t.Run("should keep certificate when files are unchanged", func(t *testing.T) {
+		r.lastCheck = time.Time{}
+		cfg, err := r.getConfigForClient(nil)
+		assert.Nil(t, err)
+		assert.Equal(t, first, cfg.Certificates[0].Certificate[0])
+	})
+
+	t.Run("should reload certificate when files change", func(t *testing.T) {
+		second := newTestCert(t, "second", ca, false)
+		second.write(t, dir, "server")
+		later := time.Now().Add(time.Minute)
+		assert.Nil(t, os.Chtimes(certFile, later, later))
+
+		r.lastCheck = time.Time{}
+		cfg, err := r.getConfigForClient(nil)
+		assert.Nil(t, err)
+		assert.Equal(t, second.cert.Raw, cfg.Certificates[0].Certificate[0])
+	})
+
+	t.Run("should keep previous certificate when new files are broken", func(t *testing.T) {
+		assert.Nil(t, ioutil.WriteFile(certFile, []byte("broken"), 0600))
+		later := time.Now().Add(2 * time.Minute)
+		assert.Nil(t, os.Chtimes(certFile, later, later))
+
+		r.lastCheck = time.Time{}
+		cfg, err := r.getConfigForClient(nil)
+		assert.Nil(t, err)
+		assert.NotNil(t, cfg.Certificates[0].Certificate[0])
+	})
+}
@@ -0,0 +1,97 @@
+package server
+
+import (
+	"errors"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/keepalive"
+)
+
+// The default of the transport config, the durations are in seconds
+const (
+	defaultMaxRecvMsgSize        = 4 * 1024 * 1024
+	defaultKeepaliveTime         = 120
+	defaultKeepaliveTimeout      = 20
+	defaultKeepaliveMinTime      = 30
+	defaultMaxConnectionAgeGrace = 30
+)
+
+var (
+	ErrMessageSizeIsNotValid       = errors.New("max recv and send message size must not be negative")
+	ErrKeepaliveTimeoutIsNotValid  = errors.New("keepalive timeout must be less than the keepalive time")
+	ErrConnectionAgeGraceIsInvalid = errors.New("max connection age grace requires the max connection age")
+)
+
+// applyTransportDefaults fill the unset transport config with the defaults
+func (c *GRPCConfig) applyTransportDefaults() {
+	if c.MaxRecvMsgSize == 0 {
+		c.MaxRecvMsgSize = defaultMaxRecvMsgSize
+	}
+
+	if c.KeepaliveTime == 0 {
+		c.KeepaliveTime = defaultKeepaliveTime
+	}
+
+	// The default timeout is kept below the configured keepalive time, so the config which only set
+	// the short keepalive time is still valid
+	if c.KeepaliveTimeout == 0 {
+		c.KeepaliveTimeout = defaultKeepaliveTimeout
+		if c.KeepaliveTime/2 < c.KeepaliveTimeout {
+			c.KeepaliveTimeout = c.KeepaliveTime / 2
+		}
+	}
+
+	if c.KeepaliveMinTime == 0 {
+		c.KeepaliveMinTime = defaultKeepaliveMinTime
+	}
+
+	if c.MaxConnectionAge > 0 && c.MaxConnectionAgeGrace == 0 {
+		c.MaxConnectionAgeGrace = defaultMaxConnectionAgeGrace
+	}
+}
+
+// ValidateTransport check the message size, keepalive and connection config
+func (c *GRPCConfig) ValidateTransport() error {
+	if c.MaxRecvMsgSize < 0 || c.MaxSendMsgSize < 0 {
+		return ErrMessageSizeIsNotValid
+	}
+
+	if c.KeepaliveTime > 0 && c.KeepaliveTimeout >= c.KeepaliveTime {
+		return ErrKeepaliveTimeoutIsNotValid
+	}
+
+	if c.MaxConnectionAgeGrace > 0 && c.MaxConnectionAge == 0 {
+		return ErrConnectionAgeGraceIsInvalid
+	}
+
+	return nil
+}
+
+// transportOptions build the message size, stream and keepalive server options from the config,
+// the unset connection idle and age keep the grpc default which is infinity
+func (c *GRPCConfig) transportOptions() []grpc.ServerOption {
+	opts := []grpc.ServerOption{
+		grpc.MaxRecvMsgSize(c.MaxRecvMsgSize),
+		grpc.KeepaliveParams(keepalive.ServerParameters{
+			MaxConnectionIdle:     seconds(c.MaxConnectionIdle),
+			MaxConnectionAge:      seconds(c.MaxConnectionAge),
+			MaxConnectionAgeGrace: seconds(c.MaxConnectionAgeGrace),
+			Time:                  seconds(c.KeepaliveTime),
+			Timeout:               seconds(c.KeepaliveTimeout),
+		}),
+		grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
+			MinTime:             seconds(c.KeepaliveMinTime),
+			PermitWithoutStream: c.KeepalivePermitWithoutStream,
+		}),
+	}
+
+	if c.MaxSendMsgSize > 0 {
+		opts = append(opts, grpc.MaxSendMsgSize(c.MaxSendMsgSize))
+	}
+
+	if c.MaxConcurrentStreams > 0 {
+		opts = append(opts, grpc.MaxConcurrentStreams(c.MaxConcurrentStreams))
+	}
+
+	return opts
+}
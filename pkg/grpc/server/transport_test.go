@@ -0,0 +1,106 @@
+package server
+
+import (
+	"context"
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/marprin/postman-lib/pkg/config"
+	"github.com/marprin/postman-lib/pkg/env"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func Test_TransportConfig(t *testing.T) {
+	t.Run("should load the transport config from the ini file", func(t *testing.T) {
+		dir := t.TempDir()
+		ini := `[grpc]
+port = 9000
+maxRecvMsgSize = 10485760
+maxConcurrentStreams = 100
+keepaliveTime = 60
+keepaliveTimeout = 10
+keepalivePermitWithoutStream = true
+maxConnectionAge = 300
+shutdownTimeout = 15
+defaultTimeout = 5
+`
+		assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "grpc."+env.Get()+".ini"), []byte(ini), 0600))
+
+		var cfg struct {
+			GRPC GRPCConfig
+		}
+		assert.Nil(t, config.ReadModuleConfigWithErr(&cfg, dir, "grpc"))
+		assert.Equal(t, uint(9000), cfg.GRPC.Port)
+		assert.Equal(t, 10485760, cfg.GRPC.MaxRecvMsgSize)
+		assert.Equal(t, uint32(100), cfg.GRPC.MaxConcurrentStreams)
+		assert.True(t, cfg.GRPC.KeepalivePermitWithoutStream)
+		assert.Equal(t, uint(15), cfg.GRPC.ShutdownTimeout)
+		assert.Equal(t, uint(5), cfg.GRPC.DefaultTimeout)
+
+		cfg.GRPC.applyTransportDefaults()
+		assert.Nil(t, cfg.GRPC.ValidateTransport())
+		assert.EqualValues(t, 60, cfg.GRPC.KeepaliveTime)
+		assert.EqualValues(t, defaultKeepaliveMinTime, cfg.GRPC.KeepaliveMinTime)
+		assert.EqualValues(t, defaultMaxConnectionAgeGrace, cfg.GRPC.MaxConnectionAgeGrace)
+	})
+
+	t.Run("should keep the default keepalive timeout below the keepalive time", func(t *testing.T) {
+		for keepaliveTime, expected := range map[uint]uint{15: 7, 20: 10, 40: 20, 120: defaultKeepaliveTimeout} {
+			cfg := GRPCConfig{KeepaliveTime: keepaliveTime}
+			cfg.applyTransportDefaults()
+			assert.Nil(t, cfg.ValidateTransport(), keepaliveTime)
+			assert.Equal(t, expected, cfg.KeepaliveTimeout, keepaliveTime)
+		}
+
+		mock, err := NewGrpcServer(&GRPCConfig{KeepaliveTime: 15}, nil).RunMock()
+		assert.Nil(t, err)
+		assert.Nil(t, mock.Stop())
+	})
+
+	t.Run("should reject the invalid config", func(t *testing.T) {
+		cases := map[error]GRPCConfig{
+			ErrMessageSizeIsNotValid:       {MaxRecvMsgSize: -1},
+			ErrKeepaliveTimeoutIsNotValid:  {KeepaliveTime: 10, KeepaliveTimeout: 10},
+			ErrConnectionAgeGraceIsInvalid: {MaxConnectionAgeGrace: 10},
+		}
+		for expected, cfg := range cases {
+			cfg := cfg
+			assert.Equal(t, expected, cfg.ValidateTransport())
+		}
+
+		_, err := NewGrpcServer(&GRPCConfig{KeepaliveTime: 10, KeepaliveTimeout: 30}, nil).RunMock()
+		assert.Equal(t, ErrKeepaliveTimeoutIsNotValid, err)
+	})
+
+	t.Run("should enforce the max recv message size", func(t *testing.T) {
+		cfg := &GRPCConfig{MaxRecvMsgSize: 1024}
+		conn := dialTestServer(t, cfg)
+
+		err := conn.Invoke(context.Background(), testUnaryMethod, wrapperspb.String(strings.Repeat("a", 2048)), &wrapperspb.StringValue{})
+		assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+		assert.Equal(t, 1024, cfg.MaxRecvMsgSize)
+
+		err = conn.Invoke(context.Background(), testUnaryMethod, wrapperspb.String("hello"), &wrapperspb.StringValue{}, grpc.WaitForReady(true))
+		assert.Nil(t, err)
+	})
+}
+
+func Test_seconds(t *testing.T) {
+	t.Run("should convert the seconds count into duration", func(t *testing.T) {
+		assert.Equal(t, time.Duration(0), seconds(0))
+		assert.Equal(t, 30*time.Second, seconds(30))
+	})
+
+	t.Run("should cap the seconds count which overflow the duration", func(t *testing.T) {
+		assert.Equal(t, time.Duration(maxSeconds)*time.Second, seconds(math.MaxUint64))
+		assert.True(t, seconds(maxSeconds+1) > 0)
+	})
+}
@@ -0,0 +1,38 @@
+package healthcheck
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/marprin/postman-lib/pkg/database"
+)
+
+// DatabaseChecker ping both the read and write connection of the store
+func DatabaseChecker(store *database.Store) Checker {
+	return CheckerFunc(func(ctx context.Context) error {
+		if err := store.GetWrite().PingContext(ctx); err != nil {
+			return fmt.Errorf("write database: %w", err)
+		}
+
+		if err := store.GetRead().PingContext(ctx); err != nil {
+			return fmt.Errorf("read database: %w", err)
+		}
+
+		return nil
+	})
+}
+
+// RedisChecker send PING through the connection from the pool
+func RedisChecker(pool *redis.Pool) Checker {
+	return CheckerFunc(func(ctx context.Context) error {
+		conn, err := pool.GetContext(ctx)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+
+		_, err = conn.Do("PING")
+		return err
+	})
+}
@@ -0,0 +1,205 @@
+package healthcheck
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+type (
+	// Checker check the dependency of the service, nil error means the dependency is healthy
+	Checker interface {
+		Check(ctx context.Context) error
+	}
+
+	// CheckerFunc adapt the function into the Checker
+	CheckerFunc func(ctx context.Context) error
+
+	// Result is the latest result of the checker
+	Result struct {
+		Name      string    `json:"name"`
+		Healthy   bool      `json:"healthy"`
+		Error     string    `json:"error,omitempty"`
+		CheckedAt time.Time `json:"checked_at"`
+	}
+
+	// Registry run the registered checkers on interval and notify the subscribers with the results
+	Registry struct {
+		interval time.Duration
+		timeout  time.Duration
+
+		mu          sync.RWMutex
+		checkers    map[string]Checker
+		results     map[string]Result
+		subscribers []func([]Result)
+	}
+)
+
+const (
+	defaultInterval = 10 * time.Second
+	defaultTimeout  = 3 * time.Second
+)
+
+// Check call the function
+func (f CheckerFunc) Check(ctx context.Context) error {
+	return f(ctx)
+}
+
+// NewRegistry create the registry, zero interval and timeout use 10 and 3 seconds
+func NewRegistry(interval, timeout time.Duration) *Registry {
+	if interval <= 0 {
+		interval = defaultInterval
+	}
+
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+
+	return &Registry{
+		interval: interval,
+		timeout:  timeout,
+		checkers: map[string]Checker{},
+		results:  map[string]Result{},
+	}
+}
+
+// Register add the checker with the name, the same name replace the previous checker
+func (r *Registry) Register(name string, c Checker) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.checkers[name] = c
+}
+
+// Subscribe register the function which is called with the results after every round of checks
+func (r *Registry) Subscribe(fn func([]Result)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.subscribers = append(r.subscribers, fn)
+}
+
+// RunChecks run every checker concurrently once and notify the subscribers
+func (r *Registry) RunChecks(ctx context.Context) []Result {
+	r.mu.RLock()
+	checkers := make(map[string]Checker, len(r.checkers))
+	for name, c := range r.checkers {
+		checkers[name] = c
+	}
+	r.mu.RUnlock()
+
+	var wg sync.WaitGroup
+	var resultMu sync.Mutex
+	results := make(map[string]Result, len(checkers))
+	for name, c := range checkers {
+		wg.Add(1)
+		go func(name string, c Checker) {
+			defer wg.Done()
+
+			checkCtx, cancel := context.WithTimeout(ctx, r.timeout)
+			defer cancel()
+
+			result := Result{Name: name, Healthy: true, CheckedAt: time.Now()}
+			if err := c.Check(checkCtx); err != nil {
+				result.Healthy = false
+				result.Error = err.Error()
+				logrus.WithField("checker", name).WithError(err).Warn("health check failed")
+			}
+
+			resultMu.Lock()
+			results[name] = result
+			resultMu.Unlock()
+		}(name, c)
+	}
+	wg.Wait()
+
+	r.mu.Lock()
+	r.results = results
+	subscribers := r.subscribers
+	r.mu.Unlock()
+
+	sorted := r.Results()
+	for _, fn := range subscribers {
+		fn(sorted)
+	}
+
+	return sorted
+}
+
+// Start run the checks immediately and then on every interval until the context is done
+func (r *Registry) Start(ctx context.Context) {
+	r.RunChecks(ctx)
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.RunChecks(ctx)
+		}
+	}
+}
+
+// Results return the latest results sorted by the name
+func (r *Registry) Results() []Result {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	results := make([]Result, 0, len(r.results))
+	for _, result := range r.results {
+		results = append(results, result)
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].Name < results[j].Name })
+
+	return results
+}
+
+// Healthy return true when every registered checker has passed its latest check
+func (r *Registry) Healthy() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for name := range r.checkers {
+		if result, ok := r.results[name]; !ok || !result.Healthy {
+			return false
+		}
+	}
+
+	return true
+}
+
+// LivenessHandler always respond 200 as long as the process is able to serve http
+func LivenessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		writeStatus(w, http.StatusOK, "ok", nil)
+	})
+}
+
+// ReadinessHandler respond 200 when every checker is healthy, otherwise 503 with the failed checks
+func (r *Registry) ReadinessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if r.Healthy() {
+			writeStatus(w, http.StatusOK, "ok", r.Results())
+			return
+		}
+		writeStatus(w, http.StatusServiceUnavailable, "unavailable", r.Results())
+	})
+}
+
+func writeStatus(w http.ResponseWriter, code int, status string, results []Result) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+
+	_ = json.NewEncoder(w).Encode(struct {
+		Status string   `json:"status"`
+		Checks []Result `json:"checks,omitempty"`
+	}{status, results})
+}
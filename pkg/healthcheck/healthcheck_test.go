@@ -0,0 +1,111 @@
+package healthcheck
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Registry(t *testing.T) {
+	t.Run("should be unhealthy before the first check", func(t *testing.T) {
+		r := NewRegistry(0, 0)
+		r.Register("mysql", CheckerFunc(func(context.Context) error { return nil }))
+		assert.False(t, r.Healthy())
+	})
+
+	t.Run("should report every checker result", func(t *testing.T) {
+		r := NewRegistry(0, 0)
+		r.Register("mysql", CheckerFunc(func(context.Context) error { return nil }))
+		r.Register("redis", CheckerFunc(func(context.Context) error { return errors.New("connection refused") }))
+
+		var notified []Result
+		r.Subscribe(func(results []Result) { notified = results })
+
+		results := r.RunChecks(context.Background())
+		assert.Equal(t, results, notified)
+		assert.Len(t, results, 2)
+		assert.Equal(t, "mysql", results[0].Name)
+		assert.True(t, results[0].Healthy)
+		assert.Equal(t, "redis", results[1].Name)
+		assert.False(t, results[1].Healthy)
+		assert.Equal(t, "connection refused", results[1].Error)
+		assert.False(t, r.Healthy())
+	})
+
+	t.Run("should fail the checker which exceed the timeout", func(t *testing.T) {
+		r := NewRegistry(0, 10*time.Millisecond)
+		r.Register("slow", CheckerFunc(func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		}))
+
+		results := r.RunChecks(context.Background())
+		assert.False(t, results[0].Healthy)
+	})
+
+	t.Run("should check on interval until the context is done", func(t *testing.T) {
+		r := NewRegistry(10*time.Millisecond, 0)
+		calls := make(chan struct{}, 10)
+		r.Register("mysql", CheckerFunc(func(context.Context) error {
+			calls <- struct{}{}
+			return nil
+		}))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			r.Start(ctx)
+			close(done)
+		}()
+
+		for i := 0; i < 2; i++ {
+			select {
+			case <-calls:
+			case <-time.After(time.Second):
+				t.Fatal("checker is not called on interval")
+			}
+		}
+		cancel()
+		<-done
+		assert.True(t, r.Healthy())
+	})
+}
+
+func Test_Handler(t *testing.T) {
+	r := NewRegistry(0, 0)
+	healthy := true
+	r.Register("mysql", CheckerFunc(func(context.Context) error {
+		if healthy {
+			return nil
+		}
+		return errors.New("down")
+	}))
+
+	t.Run("should always respond ok on liveness", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		LivenessHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("should respond ok when every checker is healthy", func(t *testing.T) {
+		r.RunChecks(context.Background())
+		rec := httptest.NewRecorder()
+		r.ReadinessHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"status":"ok"`)
+	})
+
+	t.Run("should respond unavailable when any checker is unhealthy", func(t *testing.T) {
+		healthy = false
+		r.RunChecks(context.Background())
+		rec := httptest.NewRecorder()
+		r.ReadinessHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"error":"down"`)
+	})
+}
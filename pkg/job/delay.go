@@ -3,6 +3,7 @@ package job
 import (
 	"context"
 
+	"github.com/marprin/postman-lib/pkg/requestid"
 	"github.com/marprin/postman-lib/pkg/tracing"
 	"github.com/opentracing/opentracing-go"
 	"github.com/opentracing/opentracing-go/log"
@@ -15,10 +16,15 @@ func (j *job) Delay(ctx context.Context, jobName string, params JobParam, tracer
 	// Inject Trace
 	params["uber_trace_id"] = tracing.ExtractTraceID(ctx, tracer)
 	span.SetTag("uber_trace_id", params["uber_trace_id"])
+	if reqID, ok := requestid.FromContext(ctx); ok {
+		params["request_id"] = reqID
+		span.SetTag("request_id", reqID)
+	}
 	span.SetTag("job.name", jobName)
 	span.SetTag("worker.namespace", j.workerNamespace)
 
 	resp, err := j.libWorker.Enqueue(jobName, params)
+	j.countEnqueue(jobName, err)
 	if err != nil {
 		span.SetTag("error", true).LogFields(
 			log.String("error delay job", err.Error()),
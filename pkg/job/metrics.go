@@ -0,0 +1,24 @@
+package job
+
+import (
+	"github.com/marprin/postman-lib/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var enqueuedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "job_enqueued_total",
+	Help: "Total number of jobs enqueued, partitioned by the worker namespace, job name and status.",
+}, []string{"namespace", "job", "status"})
+
+// RegisterMetrics register the enqueue counter of the job package into the registerer
+func RegisterMetrics(reg prometheus.Registerer) error {
+	return metrics.Register(reg, enqueuedTotal)
+}
+
+func (j *job) countEnqueue(jobName string, err error) {
+	status := "success"
+	if err != nil {
+		status = "error"
+	}
+	enqueuedTotal.WithLabelValues(j.workerNamespace, jobName, status).Inc()
+}
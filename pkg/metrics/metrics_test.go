@@ -0,0 +1,23 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Register(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	counter := prometheus.NewCounter(prometheus.CounterOpts{Name: "test_total", Help: "test counter"})
+
+	t.Run("should skip the collector which is already registered", func(t *testing.T) {
+		assert.Nil(t, Register(reg, counter))
+		assert.Nil(t, Register(reg, counter))
+	})
+
+	t.Run("should return error of the conflicting collector", func(t *testing.T) {
+		gauge := prometheus.NewGauge(prometheus.GaugeOpts{Name: "test_total", Help: "test gauge"})
+		assert.NotNil(t, Register(reg, gauge))
+	})
+}
@@ -0,0 +1,291 @@
+package redact
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+
+	"github.com/marprin/postman-lib/pkg/rpcmethod"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+type (
+	// Action is the way to hide the value of the sensitive field
+	Action string
+
+	// MethodRule turn down the payload logging of a method, SampleEvery log the payload of 1 in N requests
+	MethodRule struct {
+		OmitPayload bool
+		SampleEvery uint
+	}
+
+	// FieldActionFunc decide the action of the proto field, it can be used to read a custom field option
+	FieldActionFunc func(fd protoreflect.FieldDescriptor) (Action, bool)
+
+	// Config is the redaction rules, Fields is keyed by the proto or json field name
+	// and Methods is keyed by the full method, path or wildcard pattern like /postman.email.EmailService/*.
+	// HashKey is the secret key of ActionHash, the hashed field is masked when it is not set
+	Config struct {
+		Fields          map[string]Action
+		MaxStringLength int
+		Methods         map[string]MethodRule
+		FieldAction     FieldActionFunc
+		HashKey         []byte
+	}
+
+	// Redactor apply the redaction rules to the payload before it is logged
+	Redactor struct {
+		fields          map[string]Action
+		maxStringLength int
+		methods         map[string]MethodRule
+		methodPatterns  []string
+		fieldAction     FieldActionFunc
+		hashKey         []byte
+	}
+)
+
+const (
+	// ActionMask replace the value with the masked value
+	ActionMask Action = "mask"
+	// ActionHash replace the value with the truncated HMAC-SHA256 of the hash key, so the same value can still
+	// be correlated without the small value like phone number being brute-forced from the log
+	ActionHash Action = "hash"
+	// ActionOmit remove the field from the payload
+	ActionOmit Action = "omit"
+
+	// MaskedValue is the replacement of the masked field
+	MaskedValue = "[REDACTED]"
+)
+
+var (
+	defaultMu       sync.RWMutex
+	defaultRedactor = New(DefaultConfig())
+)
+
+// DefaultConfig return the rules which hide the credentials, phone numbers and recipient emails
+// and truncate large bodies, the phone numbers and recipient emails are masked until the HashKey is set
+func DefaultConfig() Config {
+	return Config{
+		Fields: map[string]Action{
+			"password":        ActionMask,
+			"secret":          ActionMask,
+			"secret_key":      ActionMask,
+			"client_secret":   ActionMask,
+			"token":           ActionMask,
+			"access_token":    ActionMask,
+			"refresh_token":   ActionMask,
+			"api_key":         ActionMask,
+			"authorization":   ActionMask,
+			"phone_number":    ActionHash,
+			"to_email":        ActionHash,
+			"x-authorization": ActionMask,
+		},
+		MaxStringLength: 256,
+	}
+}
+
+// Default return the redactor shared by the grpc server, grpc client and http response logging
+func Default() *Redactor {
+	defaultMu.RLock()
+	defer defaultMu.RUnlock()
+
+	return defaultRedactor
+}
+
+// SetDefault replace the shared redactor
+func SetDefault(r *Redactor) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+
+	defaultRedactor = r
+}
+
+// New create the redactor from the config
+func New(cfg Config) *Redactor {
+	r := &Redactor{
+		fields:          make(map[string]Action, len(cfg.Fields)),
+		maxStringLength: cfg.MaxStringLength,
+		methods:         cfg.Methods,
+		fieldAction:     cfg.FieldAction,
+		hashKey:         cfg.HashKey,
+	}
+
+	for name, action := range cfg.Fields {
+		r.fields[normalize(name)] = action
+	}
+
+	for pattern := range cfg.Methods {
+		r.methodPatterns = append(r.methodPatterns, pattern)
+	}
+
+	return r
+}
+
+// normalize make phone_number, phoneNumber and phone-number match the same rule
+func normalize(name string) string {
+	name = strings.Replace(name, "_", "", -1)
+	name = strings.Replace(name, "-", "", -1)
+	return strings.ToLower(name)
+}
+
+// methodRule return the rule of the exact method, otherwise the rule of the longest matching pattern
+func (r *Redactor) methodRule(method string) MethodRule {
+	pattern, _ := rpcmethod.LongestMatch(r.methodPatterns, method)
+	return r.methods[pattern]
+}
+
+// Sampled return true when the payload of this request of the method should be logged
+func (r *Redactor) Sampled(method string) bool {
+	rule := r.methodRule(method)
+	if rule.OmitPayload {
+		return false
+	}
+
+	if rule.SampleEvery > 1 {
+		return rand.Intn(int(rule.SampleEvery)) == 0
+	}
+
+	return true
+}
+
+// Redact return the copy of the payload which is safe to be logged
+func (r *Redactor) Redact(v interface{}) interface{} {
+	if v == nil {
+		return nil
+	}
+
+	if m, ok := v.(proto.Message); ok {
+		return r.redactMessage(m.ProtoReflect())
+	}
+
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return r.truncate(fmt.Sprintf("%+v", v))
+	}
+
+	var generic interface{}
+	if err := json.Unmarshal(raw, &generic); err != nil {
+		return r.truncate(string(raw))
+	}
+
+	return r.redactValue(generic)
+}
+
+func (r *Redactor) apply(action Action, value string) string {
+	if action == ActionHash && len(r.hashKey) > 0 {
+		mac := hmac.New(sha256.New, r.hashKey)
+		mac.Write([]byte(value))
+		return "hmac-sha256:" + hex.EncodeToString(mac.Sum(nil)[:8])
+	}
+
+	return MaskedValue
+}
+
+func (r *Redactor) truncate(s string) string {
+	if r.maxStringLength <= 0 || len(s) <= r.maxStringLength {
+		return s
+	}
+
+	return fmt.Sprintf("%s...(truncated %d bytes)", s[:r.maxStringLength], len(s)-r.maxStringLength)
+}
+
+// redactValue walk the json decoded payload and apply the field rules by the key name
+func (r *Redactor) redactValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(val))
+		for k, item := range val {
+			action, ok := r.fields[normalize(k)]
+			switch {
+			case ok && action == ActionOmit:
+			case ok:
+				out[k] = r.apply(action, fmt.Sprint(item))
+			default:
+				out[k] = r.redactValue(item)
+			}
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(val))
+		for i, item := range val {
+			out[i] = r.redactValue(item)
+		}
+		return out
+	case string:
+		return r.truncate(val)
+	}
+
+	return v
+}
+
+func (r *Redactor) protoFieldAction(fd protoreflect.FieldDescriptor) (Action, bool) {
+	if r.fieldAction != nil {
+		if action, ok := r.fieldAction(fd); ok {
+			return action, true
+		}
+	}
+
+	if action, ok := r.fields[normalize(string(fd.Name()))]; ok {
+		return action, true
+	}
+
+	action, ok := r.fields[normalize(fd.JSONName())]
+	return action, ok
+}
+
+// redactMessage walk the populated fields of the proto message and apply the field rules
+func (r *Redactor) redactMessage(m protoreflect.Message) map[string]interface{} {
+	out := map[string]interface{}{}
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		name := string(fd.Name())
+		action, ok := r.protoFieldAction(fd)
+		switch {
+		case ok && action == ActionOmit:
+		case ok:
+			out[name] = r.apply(action, fmt.Sprint(v.Interface()))
+		case fd.IsList():
+			list := v.List()
+			items := make([]interface{}, list.Len())
+			for i := 0; i < list.Len(); i++ {
+				items[i] = r.protoValue(fd, list.Get(i))
+			}
+			out[name] = items
+		case fd.IsMap():
+			items := map[string]interface{}{}
+			v.Map().Range(func(k protoreflect.MapKey, mv protoreflect.Value) bool {
+				items[k.String()] = r.protoValue(fd.MapValue(), mv)
+				return true
+			})
+			out[name] = items
+		default:
+			out[name] = r.protoValue(fd, v)
+		}
+		return true
+	})
+
+	return out
+}
+
+func (r *Redactor) protoValue(fd protoreflect.FieldDescriptor, v protoreflect.Value) interface{} {
+	switch fd.Kind() {
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return r.redactMessage(v.Message())
+	case protoreflect.EnumKind:
+		if ev := fd.Enum().Values().ByNumber(v.Enum()); ev != nil {
+			return string(ev.Name())
+		}
+		return int32(v.Enum())
+	case protoreflect.StringKind:
+		return r.truncate(v.String())
+	case protoreflect.BytesKind:
+		return fmt.Sprintf("[%d bytes]", len(v.Bytes()))
+	}
+
+	return v.Interface()
+}
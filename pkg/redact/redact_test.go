@@ -0,0 +1,129 @@
+package redact
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/marprin/postman-lib/proto/email"
+	"github.com/marprin/postman-lib/proto/sms"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+func Test_Redact(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.HashKey = []byte("log-hash-key")
+	r := New(cfg)
+
+	t.Run("should hash and truncate proto fields", func(t *testing.T) {
+		out := r.Redact(&email.Email{
+			Subject:   "Welcome",
+			FromEmail: "noreply@postman.io",
+			ToEmail:   "someone@gmail.com",
+			Body:      strings.Repeat("a", 300),
+		}).(map[string]interface{})
+
+		assert.Equal(t, "Welcome", out["subject"])
+		assert.Equal(t, "noreply@postman.io", out["from_email"])
+		assert.True(t, strings.HasPrefix(out["to_email"].(string), "hmac-sha256:"))
+		assert.NotContains(t, out["to_email"], "someone")
+		assert.True(t, strings.HasSuffix(out["body"].(string), "...(truncated 44 bytes)"))
+	})
+
+	t.Run("should hash the same value to the same result", func(t *testing.T) {
+		first := r.Redact(&sms.SMS{PhoneNumber: "81234567"}).(map[string]interface{})
+		second := r.Redact(&sms.SMS{PhoneNumber: "81234567"}).(map[string]interface{})
+		assert.Equal(t, first["phone_number"], second["phone_number"])
+	})
+
+	t.Run("should hash with the hash key", func(t *testing.T) {
+		cfg := DefaultConfig()
+		cfg.HashKey = []byte("another-key")
+		first := r.Redact(&sms.SMS{PhoneNumber: "81234567"}).(map[string]interface{})
+		second := New(cfg).Redact(&sms.SMS{PhoneNumber: "81234567"}).(map[string]interface{})
+		assert.NotEqual(t, first["phone_number"], second["phone_number"])
+	})
+
+	t.Run("should mask the hashed field without the hash key", func(t *testing.T) {
+		out := New(DefaultConfig()).Redact(&sms.SMS{PhoneNumber: "81234567"}).(map[string]interface{})
+		assert.Equal(t, MaskedValue, out["phone_number"])
+	})
+
+	t.Run("should print enum name", func(t *testing.T) {
+		out := r.Redact(&email.CreateEmailResponse{Id: "1", Status: email.EmailStatus_EMAIL_STATUS_SENT}).(map[string]interface{})
+		assert.Equal(t, "EMAIL_STATUS_SENT", out["status"])
+	})
+
+	t.Run("should redact generic payload by json key", func(t *testing.T) {
+		payload := struct {
+			Data struct {
+				PhoneNumber string `json:"phoneNumber"`
+				Password    string `json:"password"`
+			} `json:"data"`
+			Items []map[string]string `json:"items"`
+		}{}
+		payload.Data.PhoneNumber = "81234567"
+		payload.Data.Password = "secret"
+		payload.Items = []map[string]string{{"token": "abc", "name": "ok"}}
+
+		out := r.Redact(payload).(map[string]interface{})
+		data := out["data"].(map[string]interface{})
+		assert.Equal(t, MaskedValue, data["password"])
+		assert.True(t, strings.HasPrefix(data["phoneNumber"].(string), "hmac-sha256:"))
+
+		item := out["items"].([]interface{})[0].(map[string]interface{})
+		assert.Equal(t, MaskedValue, item["token"])
+		assert.Equal(t, "ok", item["name"])
+	})
+
+	t.Run("should omit field and use custom field action", func(t *testing.T) {
+		r := New(Config{
+			Fields: map[string]Action{"subject": ActionOmit},
+			FieldAction: func(fd protoreflect.FieldDescriptor) (Action, bool) {
+				if fd.Name() == "body" {
+					return ActionMask, true
+				}
+				return "", false
+			},
+		})
+		out := r.Redact(&email.Email{Subject: "Welcome", Body: "Hello"}).(map[string]interface{})
+		_, found := out["subject"]
+		assert.False(t, found)
+		assert.Equal(t, MaskedValue, out["body"])
+	})
+
+	t.Run("should return nil for nil payload", func(t *testing.T) {
+		assert.Nil(t, r.Redact(nil))
+	})
+}
+
+func Test_Sampled(t *testing.T) {
+	r := New(Config{
+		Methods: map[string]MethodRule{
+			"/postman.email.EmailService/*":         {OmitPayload: true},
+			"/postman.email.EmailService/GetStatus": {},
+			"/postman.sms.SMSService/SendSMS":       {SampleEvery: 1000000},
+		},
+	})
+
+	assert.False(t, r.Sampled("/postman.email.EmailService/CreateEmail"))
+	assert.True(t, r.Sampled("/postman.email.EmailService/GetStatus"))
+	assert.True(t, r.Sampled("/postman.other.Service/Method"))
+
+	sampled := 0
+	for i := 0; i < 100; i++ {
+		if r.Sampled("/postman.sms.SMSService/SendSMS") {
+			sampled++
+		}
+	}
+	assert.Less(t, sampled, 100)
+}
+
+func Test_Default(t *testing.T) {
+	original := Default()
+	defer SetDefault(original)
+
+	custom := New(Config{})
+	SetDefault(custom)
+	assert.Equal(t, custom, Default())
+}
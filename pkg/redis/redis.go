@@ -4,6 +4,8 @@ import (
 	"time"
 
 	"github.com/gomodule/redigo/redis"
+	"github.com/marprin/postman-lib/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
 type (
@@ -38,3 +40,21 @@ func NewRedisPool(o *Options) *redis.Pool {
 		},
 	}
 }
+
+// RegisterMetrics register the active and idle connection count of the pool into the registerer
+func RegisterMetrics(reg prometheus.Registerer, pool *redis.Pool, name string) error {
+	labels := prometheus.Labels{"pool": name}
+
+	return metrics.Register(reg,
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name:        "redis_pool_active_connections",
+			Help:        "Number of connections in the redis pool, both in use and idle.",
+			ConstLabels: labels,
+		}, func() float64 { return float64(pool.ActiveCount()) }),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name:        "redis_pool_idle_connections",
+			Help:        "Number of idle connections in the redis pool.",
+			ConstLabels: labels,
+		}, func() float64 { return float64(pool.IdleCount()) }),
+	)
+}
@@ -0,0 +1,65 @@
+package redis
+
+import (
+	"context"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+type (
+	// Store is the key value store on top of the redis pool
+	Store struct {
+		pool *redis.Pool
+	}
+)
+
+// NewStore create the key value store with the pool
+func NewStore(pool *redis.Pool) *Store {
+	return &Store{pool: pool}
+}
+
+func (s *Store) do(ctx context.Context, cmd string, args ...interface{}) (interface{}, error) {
+	conn, err := s.pool.GetContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	return conn.Do(cmd, args...)
+}
+
+// SetNX set the value with the TTL only when the key does not exist, it return false when the key exists
+func (s *Store) SetNX(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error) {
+	reply, err := redis.String(s.do(ctx, "SET", key, value, "PX", ttl.Milliseconds(), "NX"))
+	if err == redis.ErrNil {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return reply == "OK", nil
+}
+
+// Set set the value with the TTL
+func (s *Store) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	_, err := s.do(ctx, "SET", key, value, "PX", ttl.Milliseconds())
+	return err
+}
+
+// Get return the value of the key, the value is nil when the key does not exist
+func (s *Store) Get(ctx context.Context, key string) ([]byte, error) {
+	value, err := redis.Bytes(s.do(ctx, "GET", key))
+	if err == redis.ErrNil {
+		return nil, nil
+	}
+
+	return value, err
+}
+
+// Del delete the key
+func (s *Store) Del(ctx context.Context, key string) error {
+	_, err := s.do(ctx, "DEL", key)
+	return err
+}
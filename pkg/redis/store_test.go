@@ -0,0 +1,140 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeConn is the in-memory redis connection which understand the SET, GET and DEL command of the store
+type fakeConn struct {
+	values   map[string][]byte
+	commands [][]interface{}
+	err      error
+}
+
+func (c *fakeConn) Do(cmd string, args ...interface{}) (interface{}, error) {
+	if cmd == "" {
+		return nil, nil
+	}
+	if c.err != nil {
+		return nil, c.err
+	}
+	c.commands = append(c.commands, append([]interface{}{cmd}, args...))
+
+	key := args[0].(string)
+	switch cmd {
+	case "SET":
+		if len(args) > 4 && args[4] == "NX" {
+			if _, ok := c.values[key]; ok {
+				return nil, nil
+			}
+		}
+		c.values[key] = args[1].([]byte)
+		return "OK", nil
+	case "GET":
+		if value, ok := c.values[key]; ok {
+			return value, nil
+		}
+		return nil, nil
+	case "DEL":
+		if _, ok := c.values[key]; ok {
+			delete(c.values, key)
+			return int64(1), nil
+		}
+		return int64(0), nil
+	}
+
+	return nil, errors.New("ERR unknown command " + strings.ToLower(cmd))
+}
+
+func (c *fakeConn) Close() error                      { return nil }
+func (c *fakeConn) Err() error                        { return nil }
+func (c *fakeConn) Send(string, ...interface{}) error { return nil }
+func (c *fakeConn) Flush() error                      { return nil }
+func (c *fakeConn) Receive() (interface{}, error)     { return nil, nil }
+
+func newFakeStore() (*Store, *fakeConn) {
+	conn := &fakeConn{values: map[string][]byte{}}
+	pool := &redis.Pool{Dial: func() (redis.Conn, error) { return conn, nil }}
+
+	return NewStore(pool), conn
+}
+
+func Test_Store(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should set the key only when it does not exist", func(t *testing.T) {
+		store, conn := newFakeStore()
+
+		ok, err := store.SetNX(ctx, "key", []byte("first"), 1500*time.Millisecond)
+		assert.Nil(t, err)
+		assert.True(t, ok)
+
+		ok, err = store.SetNX(ctx, "key", []byte("second"), time.Second)
+		assert.Nil(t, err)
+		assert.False(t, ok)
+
+		value, err := store.Get(ctx, "key")
+		assert.Nil(t, err)
+		assert.Equal(t, []byte("first"), value)
+		assert.Equal(t, []interface{}{"SET", "key", []byte("first"), "PX", int64(1500), "NX"}, conn.commands[0])
+	})
+
+	t.Run("should overwrite the key with the TTL", func(t *testing.T) {
+		store, conn := newFakeStore()
+
+		assert.Nil(t, store.Set(ctx, "key", []byte("first"), time.Minute))
+		assert.Nil(t, store.Set(ctx, "key", []byte("second"), time.Minute))
+
+		value, err := store.Get(ctx, "key")
+		assert.Nil(t, err)
+		assert.Equal(t, []byte("second"), value)
+		assert.Equal(t, []interface{}{"SET", "key", []byte("second"), "PX", int64(60000)}, conn.commands[1])
+	})
+
+	t.Run("should return nil for the missing key", func(t *testing.T) {
+		store, _ := newFakeStore()
+
+		value, err := store.Get(ctx, "missing")
+		assert.Nil(t, err)
+		assert.Nil(t, value)
+	})
+
+	t.Run("should delete the key", func(t *testing.T) {
+		store, _ := newFakeStore()
+
+		assert.Nil(t, store.Set(ctx, "key", []byte("value"), time.Minute))
+		assert.Nil(t, store.Del(ctx, "key"))
+		assert.Nil(t, store.Del(ctx, "key"))
+
+		value, err := store.Get(ctx, "key")
+		assert.Nil(t, err)
+		assert.Nil(t, value)
+	})
+
+	t.Run("should return the connection error", func(t *testing.T) {
+		store, conn := newFakeStore()
+		conn.err = errors.New("connection refused")
+
+		_, err := store.SetNX(ctx, "key", []byte("value"), time.Minute)
+		assert.Equal(t, conn.err, err)
+		assert.Equal(t, conn.err, store.Set(ctx, "key", []byte("value"), time.Minute))
+		_, err = store.Get(ctx, "key")
+		assert.Equal(t, conn.err, err)
+		assert.Equal(t, conn.err, store.Del(ctx, "key"))
+	})
+
+	t.Run("should return the pool error", func(t *testing.T) {
+		dialErr := errors.New("dial failed")
+		store := NewStore(&redis.Pool{Dial: func() (redis.Conn, error) { return nil, dialErr }})
+
+		_, err := store.Get(ctx, "key")
+		assert.Equal(t, dialErr, err)
+	})
+}
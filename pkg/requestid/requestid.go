@@ -0,0 +1,46 @@
+package requestid
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+type contextKey struct{}
+
+// MetadataKey is the grpc metadata and http header key of the request ID
+const MetadataKey = "x-request-id"
+
+// maxLength is the maximum length of the incoming request ID, longer ID is replaced with the generated one
+const maxLength = 128
+
+// New generate a new request ID
+func New() string {
+	return uuid.New().String()
+}
+
+// Sanitize return the incoming request ID when it is usable, otherwise generate a new one
+func Sanitize(id string) string {
+	if id == "" || len(id) > maxLength {
+		return New()
+	}
+
+	for _, c := range id {
+		if c < 0x21 || c > 0x7e {
+			return New()
+		}
+	}
+
+	return id
+}
+
+// NewContext return the context with the request ID
+func NewContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// FromContext return the request ID of the context
+func FromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(contextKey{}).(string)
+	return id, ok && id != ""
+}
@@ -0,0 +1,32 @@
+package requestid
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Sanitize(t *testing.T) {
+	t.Run("should keep valid request id", func(t *testing.T) {
+		assert.Equal(t, "req-123", Sanitize("req-123"))
+	})
+
+	t.Run("should generate request id when invalid", func(t *testing.T) {
+		for _, id := range []string{"", "has space", "new\nline", strings.Repeat("a", maxLength+1)} {
+			got := Sanitize(id)
+			assert.NotEqual(t, id, got)
+			assert.NotEmpty(t, got)
+		}
+	})
+}
+
+func Test_Context(t *testing.T) {
+	_, ok := FromContext(context.Background())
+	assert.False(t, ok)
+
+	id, ok := FromContext(NewContext(context.Background(), "req-123"))
+	assert.True(t, ok)
+	assert.Equal(t, "req-123", id)
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"net/http"
 
+	"github.com/marprin/postman-lib/pkg/redact"
 	"github.com/sirupsen/logrus"
 )
 
@@ -24,18 +25,21 @@ func Response(w http.ResponseWriter, r *http.Request, httpStatusHeader int, data
 		errors,
 		meta,
 	}
+
+	// the payload is redacted with the same rules as the grpc logging, keyed by the request path
+	fields := logrus.Fields{
+		"request_uri": r.URL.Path,
+		"status_code": httpStatusHeader,
+	}
+	redactor := redact.Default()
+	if redactor.Sampled(r.URL.Path) {
+		fields["response"] = redactor.Redact(apiResponse)
+	}
+
 	if code >= http.StatusOK && code <= 299 {
-		logrus.WithFields(logrus.Fields{
-			"request_uri": r.URL.Path,
-			"response":    apiResponse,
-			"status_code": httpStatusHeader,
-		}).Infoln("API Response Success")
+		logrus.WithFields(fields).Infoln("API Response Success")
 	} else {
-		logrus.WithFields(logrus.Fields{
-			"request_uri": r.URL.Path,
-			"response":    apiResponse,
-			"status_code": httpStatusHeader,
-		}).Errorln("API Response Error")
+		logrus.WithFields(fields).Errorln("API Response Error")
 	}
 
 	w.Header().Set("Access-Control-Allow-Origin", "*")
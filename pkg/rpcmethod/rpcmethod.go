@@ -0,0 +1,35 @@
+package rpcmethod
+
+import "strings"
+
+// Match check the full method against the pattern, the pattern can be an exact full method,
+// "/package.Service/*" for every method of the service or "*" for every method
+func Match(pattern, method string) bool {
+	if pattern == "*" || pattern == method {
+		return true
+	}
+
+	if strings.HasSuffix(pattern, "/*") {
+		return strings.HasPrefix(method, strings.TrimSuffix(pattern, "*"))
+	}
+
+	return false
+}
+
+// LongestMatch return the exact method when it is in the patterns, otherwise the longest pattern which match the method
+func LongestMatch(patterns []string, method string) (string, bool) {
+	var matched string
+	found := false
+	for _, pattern := range patterns {
+		if pattern == method {
+			return pattern, true
+		}
+
+		if Match(pattern, method) && (!found || len(pattern) > len(matched)) {
+			matched = pattern
+			found = true
+		}
+	}
+
+	return matched, found
+}
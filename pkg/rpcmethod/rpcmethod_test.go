@@ -0,0 +1,43 @@
+package rpcmethod
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Match(t *testing.T) {
+	t.Run("should match the exact, service and every method pattern", func(t *testing.T) {
+		assert.True(t, Match("/postman.email.EmailService/CreateEmail", "/postman.email.EmailService/CreateEmail"))
+		assert.True(t, Match("/postman.email.EmailService/*", "/postman.email.EmailService/CreateEmail"))
+		assert.True(t, Match("*", "/postman.email.EmailService/CreateEmail"))
+	})
+
+	t.Run("should not match the other method or service", func(t *testing.T) {
+		assert.False(t, Match("/postman.email.EmailService/GetStatus", "/postman.email.EmailService/CreateEmail"))
+		assert.False(t, Match("/postman.email.EmailService/*", "/postman.email.EmailServiceV2/CreateEmail"))
+		assert.False(t, Match("/postman.email.*", "/postman.email.EmailService/CreateEmail"))
+		assert.False(t, Match("/postman.sms.SMSService/SendSMS", "/postman.email.EmailService/CreateEmail"))
+	})
+}
+
+func Test_LongestMatch(t *testing.T) {
+	patterns := []string{"*", "/postman.email.EmailService/*", "/postman.email.EmailService/CreateEmail"}
+
+	t.Run("should prefer the exact method then the longest pattern", func(t *testing.T) {
+		matched, found := LongestMatch(patterns, "/postman.email.EmailService/CreateEmail")
+		assert.True(t, found)
+		assert.Equal(t, "/postman.email.EmailService/CreateEmail", matched)
+
+		matched, _ = LongestMatch(patterns, "/postman.email.EmailService/GetStatus")
+		assert.Equal(t, "/postman.email.EmailService/*", matched)
+
+		matched, _ = LongestMatch(patterns, "/postman.sms.SMSService/SendSMS")
+		assert.Equal(t, "*", matched)
+	})
+
+	t.Run("should not find the method without matching pattern", func(t *testing.T) {
+		_, found := LongestMatch(patterns[1:], "/postman.sms.SMSService/SendSMS")
+		assert.False(t, found)
+	})
+}
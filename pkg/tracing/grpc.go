@@ -0,0 +1,212 @@
+package tracing
+
+import (
+	"context"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	"github.com/opentracing/opentracing-go/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+type (
+	// MetadataCarrier adapt the grpc metadata into the opentracing TextMap carrier
+	MetadataCarrier metadata.MD
+
+	tracedServerStream struct {
+		grpc.ServerStream
+		ctx context.Context
+	}
+
+	tracedClientStream struct {
+		grpc.ClientStream
+		desc       *grpc.StreamDesc
+		finishOnce sync.Once
+		done       chan struct{}
+		span       opentracing.Span
+	}
+)
+
+var grpcComponentTag = opentracing.Tag{Key: string(ext.Component), Value: "gRPC"}
+
+// Set implement opentracing.TextMapWriter, the grpc metadata key must be lowercase
+func (c MetadataCarrier) Set(key, val string) {
+	key = strings.ToLower(key)
+	c[key] = append(c[key], val)
+}
+
+// ForeachKey implement opentracing.TextMapReader
+func (c MetadataCarrier) ForeachKey(handler func(key, val string) error) error {
+	for k, vals := range c {
+		for _, v := range vals {
+			if err := handler(k, v); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// tracerOrGlobal return the global tracer when the tracer is nil,
+// so the interceptor pick up the tracer which is set after the interceptor is created
+func tracerOrGlobal(tracer opentracing.Tracer) opentracing.Tracer {
+	if tracer == nil {
+		return opentracing.GlobalTracer()
+	}
+
+	return tracer
+}
+
+// startServerSpan start the server span as the child of the span context from the incoming metadata
+func startServerSpan(ctx context.Context, tracer opentracing.Tracer, method string) (opentracing.Span, context.Context) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		md = metadata.MD{}
+	}
+
+	parent, err := tracer.Extract(opentracing.TextMap, MetadataCarrier(md))
+	if err != nil {
+		parent = nil
+	}
+
+	span := tracer.StartSpan(method, ext.RPCServerOption(parent), grpcComponentTag)
+	return span, opentracing.ContextWithSpan(ctx, span)
+}
+
+// startClientSpan start the client span and inject it into the outgoing metadata
+func startClientSpan(ctx context.Context, tracer opentracing.Tracer, method string) (opentracing.Span, context.Context) {
+	var parent opentracing.SpanContext
+	if parentSpan := opentracing.SpanFromContext(ctx); parentSpan != nil {
+		parent = parentSpan.Context()
+	}
+
+	span := tracer.StartSpan(method, opentracing.ChildOf(parent), ext.SpanKindRPCClient, grpcComponentTag)
+
+	md, ok := metadata.FromOutgoingContext(ctx)
+	if ok {
+		md = md.Copy()
+	} else {
+		md = metadata.MD{}
+	}
+
+	if err := tracer.Inject(span.Context(), opentracing.TextMap, MetadataCarrier(md)); err != nil {
+		span.LogFields(log.String("event", "inject failed"), log.Error(err))
+	}
+
+	return span, metadata.NewOutgoingContext(opentracing.ContextWithSpan(ctx, span), md)
+}
+
+// setSpanStatus tag the grpc status code and the error into the span
+func setSpanStatus(span opentracing.Span, err error) {
+	span.SetTag("grpc.code", status.Code(err).String())
+	if err != nil {
+		ext.Error.Set(span, true)
+		span.LogFields(log.String("event", "error"), log.String("message", err.Error()))
+	}
+}
+
+// UnaryServerInterceptor start the server span for every unary request, nil tracer use the global tracer
+func UnaryServerInterceptor(tracer opentracing.Tracer) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		span, ctx := startServerSpan(ctx, tracerOrGlobal(tracer), info.FullMethod)
+		defer span.Finish()
+
+		resp, err := handler(ctx, req)
+		setSpanStatus(span, err)
+
+		return resp, err
+	}
+}
+
+func (s *tracedServerStream) Context() context.Context {
+	return s.ctx
+}
+
+// StreamServerInterceptor start the server span for every stream request, nil tracer use the global tracer
+func StreamServerInterceptor(tracer opentracing.Tracer) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		span, ctx := startServerSpan(ss.Context(), tracerOrGlobal(tracer), info.FullMethod)
+		defer span.Finish()
+
+		err := handler(srv, &tracedServerStream{ServerStream: ss, ctx: ctx})
+		setSpanStatus(span, err)
+
+		return err
+	}
+}
+
+// UnaryClientInterceptor start the client span and propagate it to the server, nil tracer use the global tracer
+func UnaryClientInterceptor(tracer opentracing.Tracer) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		span, ctx := startClientSpan(ctx, tracerOrGlobal(tracer), method)
+		defer span.Finish()
+
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		setSpanStatus(span, err)
+
+		return err
+	}
+}
+
+func (s *tracedClientStream) finish(err error) {
+	s.finishOnce.Do(func() {
+		setSpanStatus(s.span, err)
+		s.span.Finish()
+		close(s.done)
+	})
+}
+
+func (s *tracedClientStream) SendMsg(m interface{}) error {
+	err := s.ClientStream.SendMsg(m)
+	if err != nil && err != io.EOF {
+		s.finish(err)
+	}
+	return err
+}
+
+func (s *tracedClientStream) RecvMsg(m interface{}) error {
+	err := s.ClientStream.RecvMsg(m)
+	switch {
+	case err == io.EOF:
+		s.finish(nil)
+	case err != nil:
+		s.finish(err)
+	case !s.desc.ServerStreams:
+		// client streaming only receive a single response
+		s.finish(nil)
+	}
+	return err
+}
+
+// StreamClientInterceptor start the client span for the stream which finish when the stream end
+// or the context of the stream is done, nil tracer use the global tracer
+func StreamClientInterceptor(tracer opentracing.Tracer) grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		span, ctx := startClientSpan(ctx, tracerOrGlobal(tracer), method)
+
+		cs, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			setSpanStatus(span, err)
+			span.Finish()
+			return nil, err
+		}
+
+		traced := &tracedClientStream{ClientStream: cs, desc: desc, done: make(chan struct{}), span: span}
+		go func() {
+			// the caller may cancel or abandon the stream without reading it to the end
+			select {
+			case <-ctx.Done():
+				traced.finish(status.FromContextError(ctx.Err()).Err())
+			case <-traced.done:
+			}
+		}()
+
+		return traced, nil
+	}
+}
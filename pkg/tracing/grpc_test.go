@@ -0,0 +1,179 @@
+package tracing
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+const (
+	testUnaryMethod  = "/postman.test.TestService/Ping"
+	testStreamMethod = "/postman.test.TestService/Echo"
+)
+
+var testServiceDesc = grpc.ServiceDesc{
+	ServiceName: "postman.test.TestService",
+	HandlerType: (*interface{})(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Ping",
+			Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+				in := &wrapperspb.StringValue{}
+				if err := dec(in); err != nil {
+					return nil, err
+				}
+				handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+					if req.(*wrapperspb.StringValue).Value == "fail" {
+						return nil, status.Error(codes.InvalidArgument, "invalid ping")
+					}
+					return req, nil
+				}
+				return interceptor(ctx, in, &grpc.UnaryServerInfo{Server: srv, FullMethod: testUnaryMethod}, handler)
+			},
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Echo",
+			ServerStreams: true,
+			ClientStreams: true,
+			Handler: func(srv interface{}, stream grpc.ServerStream) error {
+				for {
+					in := &wrapperspb.StringValue{}
+					if err := stream.RecvMsg(in); err != nil {
+						if err == io.EOF {
+							return nil
+						}
+						return err
+					}
+					if err := stream.SendMsg(in); err != nil {
+						return err
+					}
+				}
+			},
+		},
+	},
+}
+
+func dialTracedServer(t *testing.T, tracer opentracing.Tracer) *grpc.ClientConn {
+	lis := bufconn.Listen(1024 * 1024)
+	s := grpc.NewServer(
+		grpc.UnaryInterceptor(UnaryServerInterceptor(tracer)),
+		grpc.StreamInterceptor(StreamServerInterceptor(tracer)),
+	)
+	s.RegisterService(&testServiceDesc, struct{}{})
+	go func() { _ = s.Serve(lis) }()
+	t.Cleanup(s.Stop)
+
+	conn, err := grpc.DialContext(context.Background(), "bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return lis.Dial() }),
+		grpc.WithInsecure(),
+		grpc.WithUnaryInterceptor(UnaryClientInterceptor(tracer)),
+		grpc.WithStreamInterceptor(StreamClientInterceptor(tracer)),
+	)
+	assert.Nil(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	return conn
+}
+
+func spanByKind(spans []*mocktracer.MockSpan, kind ext.SpanKindEnum) *mocktracer.MockSpan {
+	for _, span := range spans {
+		if span.Tag(string(ext.SpanKind)) == kind {
+			return span
+		}
+	}
+
+	return nil
+}
+
+func Test_UnaryInterceptor(t *testing.T) {
+	t.Run("should propagate client span to server span", func(t *testing.T) {
+		tracer := mocktracer.New()
+		conn := dialTracedServer(t, tracer)
+
+		parent := tracer.StartSpan("caller")
+		ctx := opentracing.ContextWithSpan(context.Background(), parent)
+		err := conn.Invoke(ctx, testUnaryMethod, wrapperspb.String("hello"), &wrapperspb.StringValue{})
+		assert.Nil(t, err)
+		parent.Finish()
+
+		spans := tracer.FinishedSpans()
+		clientSpan := spanByKind(spans, ext.SpanKindRPCClientEnum)
+		serverSpan := spanByKind(spans, ext.SpanKindRPCServerEnum)
+		assert.NotNil(t, clientSpan)
+		assert.NotNil(t, serverSpan)
+
+		assert.Equal(t, testUnaryMethod, clientSpan.OperationName)
+		assert.Equal(t, parent.Context().(mocktracer.MockSpanContext).SpanID, clientSpan.ParentID)
+		assert.Equal(t, clientSpan.SpanContext.SpanID, serverSpan.ParentID)
+		assert.Equal(t, clientSpan.SpanContext.TraceID, serverSpan.SpanContext.TraceID)
+		assert.Equal(t, codes.OK.String(), serverSpan.Tag("grpc.code"))
+		assert.Nil(t, serverSpan.Tag(string(ext.Error)))
+	})
+
+	t.Run("should tag status code and error", func(t *testing.T) {
+		tracer := mocktracer.New()
+		conn := dialTracedServer(t, tracer)
+
+		err := conn.Invoke(context.Background(), testUnaryMethod, wrapperspb.String("fail"), &wrapperspb.StringValue{})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+		for _, span := range tracer.FinishedSpans() {
+			assert.Equal(t, codes.InvalidArgument.String(), span.Tag("grpc.code"))
+			assert.Equal(t, true, span.Tag(string(ext.Error)))
+		}
+		assert.Len(t, tracer.FinishedSpans(), 2)
+	})
+}
+
+func Test_StreamInterceptor(t *testing.T) {
+	tracer := mocktracer.New()
+	conn := dialTracedServer(t, tracer)
+
+	stream, err := conn.NewStream(context.Background(), &testServiceDesc.Streams[0], testStreamMethod)
+	assert.Nil(t, err)
+	assert.Nil(t, stream.SendMsg(wrapperspb.String("hello")))
+	assert.Nil(t, stream.RecvMsg(&wrapperspb.StringValue{}))
+	assert.Nil(t, stream.CloseSend())
+	assert.Equal(t, io.EOF, stream.RecvMsg(&wrapperspb.StringValue{}))
+
+	spans := tracer.FinishedSpans()
+	clientSpan := spanByKind(spans, ext.SpanKindRPCClientEnum)
+	serverSpan := spanByKind(spans, ext.SpanKindRPCServerEnum)
+	assert.NotNil(t, clientSpan)
+	assert.NotNil(t, serverSpan)
+	assert.Equal(t, clientSpan.SpanContext.SpanID, serverSpan.ParentID)
+	assert.Equal(t, codes.OK.String(), clientSpan.Tag("grpc.code"))
+}
+
+func Test_StreamInterceptorCancel(t *testing.T) {
+	t.Run("should finish the client span when the context is canceled", func(t *testing.T) {
+		tracer := mocktracer.New()
+		conn := dialTracedServer(t, tracer)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		stream, err := conn.NewStream(ctx, &testServiceDesc.Streams[0], testStreamMethod)
+		assert.Nil(t, err)
+		assert.Nil(t, stream.SendMsg(wrapperspb.String("hello")))
+		cancel()
+
+		assert.Eventually(t, func() bool {
+			return spanByKind(tracer.FinishedSpans(), ext.SpanKindRPCClientEnum) != nil
+		}, time.Second, 5*time.Millisecond)
+		clientSpan := spanByKind(tracer.FinishedSpans(), ext.SpanKindRPCClientEnum)
+		assert.Equal(t, codes.Canceled.String(), clientSpan.Tag("grpc.code"))
+	})
+}